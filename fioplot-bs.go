@@ -1,25 +1,50 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/jessevdk/go-flags"
 	bar "github.com/vk-en/fioplot-bs/pkg/barchart"
 	csv "github.com/vk-en/fioplot-bs/pkg/csvtable"
+	hist "github.com/vk-en/fioplot-bs/pkg/histchart"
 	log "github.com/vk-en/fioplot-bs/pkg/loggraphs"
+	junit "github.com/vk-en/fioplot-bs/pkg/junitreport"
+	knee "github.com/vk-en/fioplot-bs/pkg/kneechart"
+	scaling "github.com/vk-en/fioplot-bs/pkg/scalingchart"
 	xlsx "github.com/vk-en/fioplot-bs/pkg/xlsxchart"
 	bs "github.com/vk-en/fioplot-bs/pkg/bsdata"
+	data "github.com/vk-en/fioplot-bs/pkg/getdata"
+	"github.com/vk-en/fioplot-bs/pkg/units"
 )
 
 // Options - command line arguments
 type Options struct {
-	TestName    string `short:"n" long:"name" description:"Name for folder with results" required:"true"`
-	Catalog     string `short:"c" long:"catalog" description:"Full path to catalog with *.json files and/or catalogs with *.log results (Ex. /home/user/dirWithResults)" required:"true"`
-	ImgFormat   string `short:"f" long:"format" description:"Format of an images with charts" default:"png" choice:"png" choice:"svg"`
-	Description string `short:"d" long:"description" description:"Description for image results" default:"github.com/vk-en/fioplot-bs"`
-	LogGraphs   bool   `short:"l" long:"loggraphs" description:"Create log graphs" optionalArgument:"true"`
+	TestName    string   `short:"n" long:"name" description:"Name for folder with results" required:"true"`
+	Catalog     string   `short:"c" long:"catalog" description:"Full path to catalog with *.json files and/or catalogs with *.log results (Ex. /home/user/dirWithResults)" required:"true"`
+	ImgFormat   string   `short:"f" long:"format" description:"Format of an images with charts" default:"png" choice:"png" choice:"svg"`
+	Description string   `short:"d" long:"description" description:"Description for image results" default:"github.com/vk-en/fioplot-bs"`
+	LogGraphs   bool     `short:"l" long:"loggraphs" description:"Create log graphs" optionalArgument:"true"`
+	Histograms  bool     `short:"t" long:"histograms" description:"Create latency histograms and CDFs (fio results in json+ format are required)" optionalArgument:"true"`
+	Knee        bool     `long:"knee" description:"Create charts of latency by IOPS and by bandwidth for iodepths of patterns with the same rw, block size and numjobs (hockey stick)" optionalArgument:"true"`
+	Scaling     bool     `short:"s" long:"scaling" description:"Create line charts of metrics by iodepth and by numjobs for patterns with the same rw and block size, they are also added to xlsx report" optionalArgument:"true"`
+	Percentiles string   `short:"p" long:"percentiles" description:"Comma separated list of latency percentiles for reports (Ex. 50,95,99,99.9,99.99)" default:"99"`
+	PercentLat  string   `long:"percentile-lat" description:"Comma separated list of latencies for percentiles: clat, lat, slat (Ex. clat,lat)" default:"clat"`
+	Mixed       string   `short:"m" long:"mixed" description:"How to report jobs with mixed read/write loads (rw, randrw): separate pattern for each direction, combined total or both" default:"split" choice:"split" choice:"total" choice:"both"`
+	Documents   string   `long:"documents" description:"How to report several fio JSON results in one file: separate test for each result (TestA#1, TestA#2, ...) or merge them as repetitions of one test" default:"split" choice:"split" choice:"merge"`
+	GroupRegex  string   `long:"group-regex" description:"Regular expression to fold repetitions of tests into one test, the name of test is the first submatch or submatch named test (Ex. '^(.*)-run[0-9]+$')"`
+	GroupFile   string   `long:"group-file" description:"JSON file with names of repetitions for each test (Ex. {\"TestA\": [\"TestA-run1\", \"TestA-run2\"]})"`
+	Partial     bool     `long:"partial" description:"Report patterns which are only in some tests, missing results are empty cells in xlsx, absent bars on charts and n/a in CSV"`
+	Baseline    string   `short:"b" long:"baseline" description:"Name of test which other tests are compared with, percent deltas are added to reports (Ex. TestA)"`
+	Check       string   `long:"check" description:"JSON file with allowed regressions of metrics (%) for check of candidates against baseline, exit code is 2 if check failed (Ex. {\"metrics\": {\"Performance\": 5, \"cLatency_p99\": 10}})"`
+	JUnit       string   `long:"junit" description:"Path to JUnit XML file with results of check against baseline (--check is required)"`
+	Candidates  []string `long:"candidate" description:"Name of test which is checked against baseline, can be repeated (all tests except baseline by default)"`
+	PatternKey  string   `short:"k" long:"pattern-key" description:"How jobs are matched between tests: tuple of rw, bs, iodepth and numjobs, job name, tuple with ioengine or template with fields {job}, {rw}, {bs}, {iodepth}, {numjobs}, {ioengine} (Ex. {rw}-{bs}-qd{iodepth})" default:"tuple"`
+	Sort        string   `long:"sort" description:"Comma separated order of keys for sorting of patterns: rw, bs, iodepth, numjobs, direction (Ex. bs,rw,iodepth,numjobs)" default:"rw,bs,iodepth,numjobs"`
+	Units       string   `short:"u" long:"units" description:"Comma separated units of latency (ns, µs or us, ms, s), bandwidth (B/s, KiB/s, MiB/s, GiB/s) and/or offsets of IOs (B, KiB, MiB, GiB, TiB) for all xlsx tables and charts, by default units are chosen for each table and chart by range of values (Ex. us,MiB/s)" default:"auto"`
 }
 
 const (
@@ -27,6 +52,9 @@ const (
 )
 
 var opts Options
+
+// errCheckFailed - candidates have regressions more than thresholds
+var errCheckFailed = errors.New("check of candidates against baseline failed")
 var parser = flags.NewParser(&opts, flags.Default)
 var pathToResults string
 var csvFiles []string
@@ -68,7 +96,7 @@ func createFolderForResults(folderName string) (string, error) {
 }
 
 // createCSVTable - create CSV table from JSON file
-func createCSVTables(allTestInfo bs.AllTestInfo) error {
+func createCSVTables(allTestInfo bs.AllTestInfo, reportOpts data.Options) error {
 	csvFolderPath := filepath.Join(allTestInfo.MainPathToResults, "csv-tables")
 	if _, err := os.Stat(csvFolderPath); os.IsNotExist(err) {
 		if err := os.Mkdir(csvFolderPath, 0755); err != nil {
@@ -79,7 +107,10 @@ func createCSVTables(allTestInfo bs.AllTestInfo) error {
 	for _, testResults := range allTestInfo.Tests {
 		testResults.CSVFileName = fmt.Sprintf("%s.%s", testResults.TestName, "csv")
 		testResults.CSVFilePath = filepath.Join(csvFolderPath, testResults.CSVFileName)
-		if err := csv.ConvertJSONtoCSV(testResults.JSONResults, testResults.CSVFilePath); err != nil {
+		if err := csv.ConvertJSONtoCSV(testResults.JSONResults, testResults.CSVFilePath, csv.Options{
+			Percentiles: reportOpts.Percentiles,
+			SortOrder:   reportOpts.SortOrder,
+		}); err != nil {
 			fmt.Printf("could not create CSV table for file [%s]\n. Error: %v\n",
 						 testResults.TestName, err)
 			continue
@@ -91,6 +122,88 @@ func createCSVTables(allTestInfo bs.AllTestInfo) error {
 	return nil
 }
 
+// setGroups - fold repetitions of tests: several JSON documents in one file (--documents=merge),
+// then by regular expression and then by file with groups
+func setGroups(allResults bs.AllTestInfo, reportOpts *data.Options) error {
+	var testNames []string
+	for _, test := range allResults.Tests {
+		testNames = append(testNames, test.TestName)
+		if test.Group != "" {
+			reportOpts.Groups[test.TestName] = test.Group
+		}
+	}
+
+	if opts.GroupRegex != "" {
+		groups, err := data.GroupsByRegexp(testNames, opts.GroupRegex)
+		if err != nil {
+			return err
+		}
+		for test, group := range groups {
+			reportOpts.Groups[test] = group
+		}
+	}
+
+	if opts.GroupFile != "" {
+		groups, err := data.GroupsFromFile(opts.GroupFile)
+		if err != nil {
+			return err
+		}
+		for test, group := range groups {
+			reportOpts.Groups[test] = group
+		}
+	}
+	return nil
+}
+
+// checkRegressions - compare candidates with baseline using thresholds from file
+func checkRegressions(reportOpts data.Options) error {
+	thresholds, err := data.ReadThresholds(opts.Check)
+	if err != nil {
+		return err
+	}
+	results, err := data.CheckRegressions(csvFiles, reportOpts, opts.Candidates, thresholds)
+	if err != nil {
+		return fmt.Errorf("could not check candidates against baseline: %w", err)
+	}
+	// the table is printed even if JUnit report can't be written, failed check has priority over its error
+	failed := data.PrintCheckResults(os.Stdout, results)
+	if opts.JUnit != "" {
+		if err := junit.CreateJUnitReport(results, opts.JUnit); err != nil {
+			if failed == 0 {
+				return err
+			}
+			fmt.Println(err)
+		}
+	}
+	if failed != 0 {
+		return errCheckFailed
+	}
+	return nil
+}
+
+// setBaseline - check that baseline test is in results
+func setBaseline(allResults bs.AllTestInfo, reportOpts *data.Options) error {
+	if opts.JUnit != "" && opts.Check == "" {
+		return fmt.Errorf("file with thresholds is required for JUnit report (--check)")
+	}
+	if opts.Baseline == "" {
+		if opts.Check != "" {
+			return fmt.Errorf("baseline test is required for check of candidates (--baseline)")
+		}
+		return nil
+	}
+	var tests []string
+	for _, test := range allResults.Tests {
+		name := reportOpts.GroupName(test.TestName)
+		if name == opts.Baseline {
+			reportOpts.Baseline = opts.Baseline
+			return nil
+		}
+		tests = append(tests, name)
+	}
+	return fmt.Errorf("baseline test [%s] not found, tests: %s", opts.Baseline, strings.Join(tests, ", "))
+}
+
 // makeResults - create folder with results (xlsx, csv, and BarChars img)
 func makeResults() error {
 	var err error
@@ -98,17 +211,68 @@ func makeResults() error {
 		return fmt.Errorf("could not create folder for results: %w", err)
 	}
 
+	mixedMode, err := data.ParseMixedMode(opts.Mixed)
+	if err != nil {
+		cleanUpDir()
+		return err
+	}
+	percentiles, err := csv.ParseLatencyPercentiles(opts.PercentLat, opts.Percentiles)
+	if err != nil {
+		cleanUpDir()
+		return err
+	}
+	documentsMode, err := bs.ParseDocumentsMode(opts.Documents)
+	if err != nil {
+		cleanUpDir()
+		return err
+	}
+	sortOrder, err := bs.ParseSortOrder(opts.Sort)
+	if err != nil {
+		cleanUpDir()
+		return err
+	}
+	patternKey, err := data.ParsePatternKey(opts.PatternKey)
+	if err != nil {
+		cleanUpDir()
+		return err
+	}
+	unitSystem, err := units.Parse(opts.Units)
+	if err != nil {
+		cleanUpDir()
+		return err
+	}
+	reportOpts := data.Options{
+		Mixed:       mixedMode,
+		Percentiles: percentiles,
+		Groups:      make(map[string]string),
+		Partial:     opts.Partial,
+		SortOrder:   sortOrder,
+		PatternKey:  patternKey,
+		Scaling:     opts.Scaling,
+		Units:       unitSystem,
+	}
+
 	fmt.Println("This process will take some time, please wait...")
-	allResults, err := bs.ReadAllJSONFiles(opts.Catalog)
+	allResults, err := bs.ReadAllFiles(opts.Catalog, documentsMode)
+	bs.PrintInputFiles(os.Stdout, allResults.Inputs)
 	if err != nil {
 		cleanUpDir()
 		return fmt.Errorf("could not read all JSON files: %w", err)
 	}
+	if err := setGroups(allResults, &reportOpts); err != nil {
+		cleanUpDir()
+		return err
+	}
+	if err := setBaseline(allResults, &reportOpts); err != nil {
+		cleanUpDir()
+		return err
+	}
 
 	allResults.MainPathToResults = pathToResults
 	allResults.PathWithSrcResults = opts.Catalog
 	allResults.ImgFormat = opts.ImgFormat
 	allResults.Description = opts.Description
+	allResults.Units = unitSystem
 
 	if opts.LogGraphs {
 		if err := log.CreateGraphsFromLogs(allResults); err != nil {
@@ -117,7 +281,7 @@ func makeResults() error {
 	}
 
 	// Create CSV tables for each JSON file
- 	if err := createCSVTables(allResults); err != nil {
+ 	if err := createCSVTables(allResults, reportOpts); err != nil {
 		return fmt.Errorf("could not create CSV tables: %w", err)
 	}
 
@@ -128,19 +292,61 @@ func makeResults() error {
 			"Results and graphs were not generated =("))
 	}
 
- 	if err := xlsx.CreateXlsxReport(csvFiles, pathToResults); err != nil {
+	coverageFile := filepath.Join(pathToResults, "csv-tables", "coverage.csv")
+	if coverage, err := data.CreateCoverageCSV(csvFiles, coverageFile, reportOpts); err != nil {
+		fmt.Printf("could not create CSV table with coverage of patterns.\n Error: %v\n", err)
+	} else {
+		if partial := coverage.Partial(); len(partial) != 0 && !opts.Partial {
+			fmt.Printf("%d of %d patterns are not in all tests and are skipped, see %s or use --partial\n",
+				len(partial), len(coverage.Patterns), coverageFile)
+		}
+		for _, duplicate := range coverage.Duplicates {
+			fmt.Printf("%s, only the first job is reported (use group_reporting or --pattern-key to distinguish jobs)\n", duplicate)
+		}
+	}
+
+	if len(reportOpts.Groups) != 0 {
+		statsFile := filepath.Join(pathToResults, "csv-tables", "repetitions-stats.csv")
+		if err := data.CreateStatsCSV(csvFiles, statsFile, reportOpts); err != nil {
+			fmt.Printf("could not create CSV table with statistics of repetitions.\n Error: %v\n", err)
+		}
+	}
+
+ 	if err := xlsx.CreateXlsxReport(csvFiles, pathToResults, reportOpts); err != nil {
 		// not a critical error, can move next, just log it
 		fmt.Printf("could not create xsls file.\n Error: %v\n", err)
 	}
 
 	//Create bar charts
-	if err := bar.CreateBarCharts(csvFiles, opts.Description, pathToResults, opts.ImgFormat); err != nil {
+	if err := bar.CreateBarCharts(csvFiles, opts.Description, pathToResults, opts.ImgFormat, reportOpts); err != nil {
 		fmt.Printf("could not create barCharts.\n Error: %v\n", err)
 	} else {
 		fmt.Println("Results and graphs were generated successfully!")
 	}
 
+	if opts.Histograms {
+		if err := hist.CreateLatencyCharts(allResults, reportOpts); err != nil {
+			fmt.Printf("could not create latency histograms.\n Error: %v\n", err)
+		}
+	}
+
+	if opts.Knee {
+		if err := knee.CreateKneeCharts(allResults, reportOpts); err != nil {
+			fmt.Printf("could not create knee charts.\n Error: %v\n", err)
+		}
+	}
+
+	if opts.Scaling {
+		if err := scaling.CreateScalingCharts(csvFiles, opts.Description, pathToResults, opts.ImgFormat, reportOpts); err != nil {
+			fmt.Printf("could not create scaling charts.\n Error: %v\n", err)
+		}
+	}
+
 	fmt.Println("Results are in folder:", pathToResults)
+
+	if opts.Check != "" {
+		return checkRegressions(reportOpts)
+	}
 	return nil
 }
 
@@ -155,6 +361,10 @@ func main() {
 	argparse()
 	if err := makeResults(); err != nil {
 		fmt.Println(err)
+		if errors.Is(err, errCheckFailed) {
+			os.Exit(2)
+		}
+		os.Exit(1)
 	}
 }
 
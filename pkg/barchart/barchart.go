@@ -2,21 +2,26 @@ package barchart
 
 import (
 	"fmt"
+	"image/color"
+	"math"
 	"os"
 	"path/filepath"
 
+	bs "github.com/vk-en/fioplot-bs/pkg/bsdata"
 	data "github.com/vk-en/fioplot-bs/pkg/getdata"
 	"gonum.org/v1/plot"
 	"gonum.org/v1/plot/font"
 	"gonum.org/v1/plot/plotter"
 	"gonum.org/v1/plot/plotutil"
 	"gonum.org/v1/plot/vg"
+	"gonum.org/v1/plot/vg/draw"
 )
 
 // allLegendResults - structure for storing data for all legends
 type allLegendResults struct {
 	legend       string
 	value        []float64
+	ci           []float64 // half-width of 95% confidence interval over repetitions
 	pattern      []string
 	yDiscription string
 	fileName     string
@@ -25,17 +30,78 @@ type allLegendResults struct {
 // legensTable - structure for storing data for all legends
 type legensTable []*allLegendResults
 
-const (
-	performance uint16 = 1 << iota
-	minIOPS
-	maxIOPS
-	minBW
-	maxBW
-	minLat
-	maxLat
-	stdLat
-	p99Lat
-)
+
+// patternCI - half-width of 95% confidence interval of value with index, NaN without statistics
+func patternCI(pattern *data.AllPatternResults, index int) float64 {
+	if index >= len(pattern.Stats) {
+		return math.NaN()
+	}
+	return pattern.Stats[index].CI95
+}
+
+// addBars - bars of values at positions 0, 1, ... of category axis shifted by offset. Missing values (NaN)
+// have no bar and are marked with n/a, so they are not confused with measured zero (Ex. IOPS min 0).
+// Returns bars for legend
+func addBars(p *plot.Plot, values []float64, width, offset vg.Length, c color.Color, horizontal bool) (*plotter.BarChart, error) {
+	var legend *plotter.BarChart
+	var missing plotter.XYs
+	for i, value := range values {
+		if math.IsNaN(value) {
+			if horizontal {
+				missing = append(missing, plotter.XY{Y: float64(i)})
+			} else {
+				missing = append(missing, plotter.XY{X: float64(i)})
+			}
+			continue
+		}
+		bars, err := plotter.NewBarChart(plotter.Values{value}, width)
+		if err != nil {
+			return nil, err
+		}
+		bars.XMin = float64(i)
+		bars.Offset = offset
+		bars.Horizontal = horizontal
+		bars.LineStyle.Width = vg.Length(0)
+		bars.Color = c
+		p.Add(bars)
+		if legend == nil {
+			legend = bars
+		}
+	}
+	if legend == nil {
+		// all values are missing, bars are only for legend
+		legend, _ = plotter.NewBarChart(plotter.Values{0}, width)
+		legend.LineStyle.Width = vg.Length(0)
+		legend.Color = c
+	}
+	if len(missing) == 0 {
+		return legend, nil
+	}
+
+	names := make([]string, len(missing))
+	for i := range names {
+		names[i] = "n/a"
+	}
+	labels, err := plotter.NewLabels(plotter.XYLabels{XYs: missing, Labels: names})
+	if err != nil {
+		return nil, err
+	}
+	for i := range labels.TextStyle {
+		labels.TextStyle[i].Font.Size = font.Length(7)
+		labels.TextStyle[i].XAlign = draw.XLeft
+		labels.TextStyle[i].YAlign = draw.YCenter
+		if !horizontal {
+			labels.TextStyle[i].Rotation = math.Pi / 2
+		}
+	}
+	if horizontal {
+		labels.Offset = vg.Point{Y: offset}
+	} else {
+		labels.Offset = vg.Point{X: offset}
+	}
+	p.Add(labels)
+	return legend, nil
+}
 
 //plotCreate - сreates a skeleton for plotting graphs
 func plotCreate(testName, typeVolume, description string, xMax float64) (*plot.Plot, error) {
@@ -87,6 +153,7 @@ func (t *legensTable) getLegendsTable(patternTable data.PatternsTable) {
 			for g := 0; g < len(pattern.Values); g++ {
 				if ilegend.legend == pattern.Legends[g] {
 					ilegend.value = append(ilegend.value, pattern.Values[g])
+					ilegend.ci = append(ilegend.ci, patternCI(pattern, g))
 					ilegend.pattern = append(ilegend.pattern, pattern.PatternName)
 				}
 			}
@@ -105,23 +172,19 @@ func createGeneralBarCharts(table data.PatternsTable, description, dirPath, imgT
 	w := vg.Points(3)
 	start := 0 - w
 	for k := 0; k < len(lTable); k++ {
-		var data plotter.Values
-		data = lTable[k].value
-		bars, _ := plotter.NewBarChart(data, w)
-		bars.LineStyle.Width = vg.Length(0)
-		bars.Width = font.Length(2)
-		bars.Color = plotutil.Color(k)
-
 		start = start + w
-		bars.Offset = start
-		p.Add(bars)
+		bars, err := addBars(p, lTable[k].value, font.Length(2), start, plotutil.Color(k), false)
+		if err != nil {
+			return fmt.Errorf("generate BarCharts failed! err:%v", err)
+		}
+		p.Add(newErrorBars(lTable[k].value, lTable[k].ci, start, bars.Width))
 		p.Legend.Add(lTable[k].legend, bars)
 	}
 
 	p.X.Tick.Label.Rotation = -125
 	ticks := make([]plot.Tick, len(table))
 	for i, name := range table {
-		ticks[i] = plot.Tick{float64(i), name.PatternName}
+		ticks[i] = plot.Tick{Value: float64(i), Label: name.PatternName}
 	}
 	p.X.Tick.Width = font.Length(8)
 	p.X.Tick.Marker = plot.ConstantTicks(ticks)
@@ -149,20 +212,17 @@ func createSeparateBarCharts(table data.PatternsTable, description, dirPath, img
 		p.NominalX(pattern.PatternName)
 		start := 0 - w
 		for i := 0; i < len(pattern.Values); i++ {
-			bars, err := plotter.NewBarChart(plotter.Values{pattern.Values[i]}, w)
+			start = start + w
+			bars, err := addBars(p, []float64{pattern.Values[i]}, font.Length(4), start, plotutil.Color(i), false)
 			if err != nil {
 				return fmt.Errorf("generate BarCharts for [%s] failed! err:%v", pattern.PatternName, err)
 			}
-			bars.LineStyle.Width = vg.Length(0)
-			bars.Color = plotutil.Color(i)
-			bars.Width = font.Length(4)
-			start = start + w
-			bars.Offset = start
-			p.Add(bars)
+			p.Add(newErrorBars([]float64{pattern.Values[i]},
+				[]float64{patternCI(pattern, i)}, start, bars.Width))
 			p.Legend.Add(pattern.Legends[i], bars)
 		}
 		if err := p.Save(4*vg.Inch, 7*vg.Inch,
-			filepath.Join(resultsAbsDir, fmt.Sprintf("%s.%s", pattern.PatternName, imgType))); err != nil {
+			filepath.Join(resultsAbsDir, fmt.Sprintf("%s.%s", bs.FileName(pattern.PatternName), imgType))); err != nil {
 			return fmt.Errorf("generate BarCharts for [%s] failed! err:%v",
 				pattern.PatternName, err)
 		}
@@ -170,8 +230,9 @@ func createSeparateBarCharts(table data.PatternsTable, description, dirPath, img
 	return nil
 }
 
-// CreateBarCharts - generate bar charts for all groups between different tests
-func CreateBarCharts(csvFiles []string, descriptionForCharts, pathForResults, imgType string) error {
+// CreateBarCharts - generate bar charts for all groups between different tests.
+// Bars of repetitions of one test have error bars with 95% confidence interval
+func CreateBarCharts(csvFiles []string, descriptionForCharts, pathForResults, imgType string, opts data.Options) error {
 	var testResults = make(data.AllResults, 0)
 
 	barChartAbsDir := filepath.Join(pathForResults, "bar-charts")
@@ -181,19 +242,34 @@ func CreateBarCharts(csvFiles []string, descriptionForCharts, pathForResults, im
 	}
 
 	for _, file := range csvFiles {
-		if err := testResults.ParsingCSVfile(file); err != nil {
+		if err := testResults.ParsingCSVfile(file, opts); err != nil {
 			return fmt.Errorf("parsing csv file [%s] failed! err:%v", file, err)
 		}
 	}
 
-	identicalPatterns, err := data.GetIdenticalPatterns(testResults)
+	identicalPatterns, err := data.GetPatterns(testResults, opts)
 	if err != nil {
-		return fmt.Errorf("could not get identical patterns: %w", err)
+		return fmt.Errorf("could not get patterns for report: %w", err)
+	}
+
+	deltaDir := ""
+	if opts.Baseline != "" {
+		if deltaDir, err = createDeltaDir(barChartAbsDir); err != nil {
+			return err
+		}
 	}
 
-	for _, valRes := range []uint16{performance, minIOPS, maxIOPS, minBW, maxBW, minLat, maxLat, stdLat, p99Lat} {
+	for _, metric := range data.Metrics(opts) {
 		var pTable = make(data.PatternsTable, 0)
-		pTable.GetPatternTable(identicalPatterns, testResults, valRes)
+		pTable.GetPatternTable(identicalPatterns, testResults, metric)
+		if opts.Baseline != "" {
+			if err := pTable.CompareWithBaseline(opts.Baseline); err != nil {
+				return err
+			}
+			if err := createDeltaBarChart(pTable, metric, opts.Baseline, descriptionForCharts, deltaDir, imgType); err != nil {
+				return err
+			}
+		}
 		if err := createSeparateBarCharts(pTable, descriptionForCharts, barChartAbsDir, imgType); err != nil {
 			return fmt.Errorf("generate BarChart failed! err:%v", err)
 		}
@@ -0,0 +1,101 @@
+package barchart
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+
+	data "github.com/vk-en/fioplot-bs/pkg/getdata"
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/font"
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/plotutil"
+	"gonum.org/v1/plot/vg"
+)
+
+// deltaAxisName - name of axis with deltas, it explains which direction is better
+func deltaAxisName(metric data.Metric, baseline string) string {
+	better := "higher is better"
+	if metric.LowerIsBetter {
+		better = "lower is better"
+	}
+	return fmt.Sprintf("%s: change to %s (%%), %s", metric.YDiscription, baseline, better)
+}
+
+// createDeltaBarChart - generate diverging bar chart with deltas of tests to baseline for each pattern
+func createDeltaBarChart(table data.PatternsTable, metric data.Metric, baseline, description, dirPath, imgType string) error {
+	if len(table) == 0 {
+		return nil
+	}
+
+	p := plot.New()
+	p.Title.Text = fmt.Sprintf("%s compared with %s", metric.FileName, baseline)
+	p.Title.TextStyle.Font.Size = font.Length(20)
+	p.Title.Padding = 20
+	p.X.Label.Text = fmt.Sprintf("%s\n\n%s", deltaAxisName(metric, baseline), description)
+	p.X.Label.Padding = 10
+	p.Legend.Top = true
+	p.Legend.Padding = 2
+	p.Add(plotter.NewGrid())
+
+	var names []string
+	for _, pattern := range table {
+		names = append(names, pattern.PatternName)
+	}
+	p.NominalY(names...)
+
+	w := vg.Points(6)
+	start := 0 - w
+	maxDelta := 1.0
+	for index, legend := range table[0].Legends {
+		if legend == baseline {
+			continue
+		}
+		var deltas plotter.Values
+		for _, pattern := range table {
+			delta := math.NaN()
+			if i := pattern.LegendIndex(legend); i >= 0 && i < len(pattern.Deltas) {
+				delta = pattern.Deltas[i]
+			}
+			deltas = append(deltas, delta)
+			if !math.IsNaN(delta) {
+				maxDelta = math.Max(maxDelta, math.Abs(delta))
+			}
+		}
+		start = start + w
+		bars, err := addBars(p, deltas, font.Length(5), start, plotutil.Color(index), true)
+		if err != nil {
+			return fmt.Errorf("could not create bars for test [%s]: %w", legend, err)
+		}
+		p.Legend.Add(legend, bars)
+	}
+
+	zero, err := plotter.NewLine(plotter.XYs{{X: 0, Y: -0.5}, {X: 0, Y: float64(len(table)) - 0.5}})
+	if err != nil {
+		return fmt.Errorf("could not create zero line: %w", err)
+	}
+	zero.Width = vg.Points(1)
+	p.Add(zero)
+	// symmetric axis, so improvements and regressions are on different sides of zero
+	p.X.Min, p.X.Max = -maxDelta*1.05, maxDelta*1.05
+
+	height := vg.Length(len(table)) * vg.Inch / 2
+	if height < 5*vg.Inch {
+		height = 5 * vg.Inch
+	}
+	filePath := filepath.Join(dirPath, fmt.Sprintf("%s.%s", metric.FileName, imgType))
+	if err := p.Save(12*vg.Inch, height, filePath); err != nil {
+		return fmt.Errorf("generate delta BarChart failed! err:%v", err)
+	}
+	return nil
+}
+
+// createDeltaDir - directory for diverging bar charts with deltas to baseline
+func createDeltaDir(barChartAbsDir string) (string, error) {
+	deltaDir := filepath.Join(barChartAbsDir, "delta")
+	if err := os.MkdirAll(deltaDir, 0755); err != nil {
+		return "", fmt.Errorf("could not create dir for delta BarCharts: %w", err)
+	}
+	return deltaDir, nil
+}
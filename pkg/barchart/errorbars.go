@@ -0,0 +1,73 @@
+package barchart
+
+import (
+	"math"
+
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/vg"
+	"gonum.org/v1/plot/vg/draw"
+)
+
+// errorBars - error bars over bars of one legend. plotter.YErrorBars can't be used
+// because bars of legends are shifted by offset in canvas units, not in data units
+type errorBars struct {
+	means  []float64
+	errors []float64 // half-width of error bar, NaN or 0 if there is no error bar
+	offset vg.Length
+	cap    vg.Length
+	draw.LineStyle
+}
+
+// newErrorBars - error bars for values with 95% confidence intervals of legend
+func newErrorBars(means, errors []float64, offset, width vg.Length) *errorBars {
+	return &errorBars{
+		means:     means,
+		errors:    errors,
+		offset:    offset,
+		cap:       width,
+		LineStyle: draw.LineStyle{Color: plotter.DefaultLineStyle.Color, Width: vg.Points(0.5)},
+	}
+}
+
+// hasError - true if there is error bar for value with index
+func (e *errorBars) hasError(i int) bool {
+	return i < len(e.errors) && !math.IsNaN(e.means[i]) && !math.IsNaN(e.errors[i]) && e.errors[i] > 0
+}
+
+// Plot implements the plot.Plotter interface
+func (e *errorBars) Plot(c draw.Canvas, plt *plot.Plot) {
+	trX, trY := plt.Transforms(&c)
+	for i := range e.means {
+		if !e.hasError(i) {
+			continue
+		}
+		x := trX(float64(i))
+		if !c.ContainsX(x) {
+			continue
+		}
+		x += e.offset
+		low := trY(math.Max(e.means[i]-e.errors[i], 0))
+		high := trY(e.means[i] + e.errors[i])
+		c.StrokeLine2(e.LineStyle, x, low, x, high)
+		c.StrokeLine2(e.LineStyle, x-e.cap/2, low, x+e.cap/2, low)
+		c.StrokeLine2(e.LineStyle, x-e.cap/2, high, x+e.cap/2, high)
+	}
+}
+
+// DataRange implements the plot.DataRanger interface
+func (e *errorBars) DataRange() (xmin, xmax, ymin, ymax float64) {
+	xmax = float64(len(e.means) - 1)
+	ymin, ymax = math.Inf(1), math.Inf(-1)
+	for i, mean := range e.means {
+		if !e.hasError(i) {
+			continue
+		}
+		ymin = math.Min(ymin, math.Max(mean-e.errors[i], 0))
+		ymax = math.Max(ymax, mean+e.errors[i])
+	}
+	if math.IsInf(ymin, 1) {
+		ymin, ymax = 0, 0
+	}
+	return 0, xmax, ymin, ymax
+}
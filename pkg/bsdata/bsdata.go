@@ -6,7 +6,12 @@ import (
 	"fmt"
 	"io/ioutil"
 	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
 	"strings"
+
+	"github.com/vk-en/fioplot-bs/pkg/units"
 )
 
 type LogFileType int
@@ -17,16 +22,29 @@ const (
 	LOG_TYPE_CLAT
 	LOG_TYPE_SLAT
 	LOG_TYPE_LAT
+	LOG_TYPE_CLAT_HIST
 	LOG_TYPE_MAX
 )
 
+// Direction is enum for data direction of IO operations in fio results
+type Direction int
+
+const (
+	DIR_READ Direction = iota
+	DIR_WRITE
+	DIR_TRIM
+	DIR_MAX
+)
+
 // LatNS is a struct for latency in nanoseconds
 type LatNS struct {
 	Min        int64            `json:"min"`
 	Max        int64            `json:"max"`
 	Mean       float64          `json:"mean"`
 	Stddev     float64          `json:"stddev"`
+	N          int64            `json:"N"`
 	Percentile map[string]int64 `json:"percentile,omitempty"`
+	Bins       map[string]int64 `json:"bins,omitempty"` // only in json+ output: latency in ns -> count of IOs
 }
 
 // Operation is a struct for FIO JSON input
@@ -62,15 +80,7 @@ type Jobs struct {
 	Error      int    `json:"error"`
 	Eta        int    `json:"eta"`
 	Elapsed    int    `json:"elapsed"`
-	TestOption struct {
-		RW      string `json:"rw"`
-		BS      string `json:"bs"`
-		IODepth string `json:"iodepth"`
-		NumJobs string `json:"numjobs"`
-		BwLog   string `json:"write_bw_log"`
-		IOPSLog string `json:"write_iops_log"`
-		LatLog  string `json:"write_lat_log"`
-	} `json:"job options"`
+	TestOption JobOptions `json:"job options"`
 	Read              OperationRW `json:"read"`
 	Write             OperationRW `json:"write"`
 	Trim              OperationRW `json:"trim"`
@@ -99,16 +109,45 @@ type DiskUtil struct {
 	Util        float64 `json:"util"`
 }
 
-// GlobalOptions is a struct for FIO JSON input
-type GlobalOptions struct {
+// JobOptions is a struct for options of jobs in FIO JSON input.
+// fio writes to "job options" only the keys which override "global options",
+// so the full options of job are returned by Jobs.EffectiveOptions
+type JobOptions struct {
+	RW             string `json:"rw"`
+	BS             string `json:"bs"`
+	IODepth        string `json:"iodepth"`
+	NumJobs        string `json:"numjobs"`
+	RWMixRead      string `json:"rwmixread"`
+	RWMixWrite     string `json:"rwmixwrite"`
 	Ioengine       string `json:"ioengine"`
 	Size           string `json:"size"`
 	Direct         string `json:"direct"`
 	Runtime        string `json:"runtime"`
+	RampTime       string `json:"ramp_time"`
 	TimeBased      string `json:"time_based"`
 	GroupReporting string `json:"group_reporting"`
-	LogAvgMsec     string `json:"log_avg_msec"`
 	Filename       string `json:"filename"`
+	Directory      string `json:"directory"`
+	LogAvgMsec     string `json:"log_avg_msec"`
+	BwLog          string `json:"write_bw_log"`
+	IOPSLog        string `json:"write_iops_log"`
+	LatLog         string `json:"write_lat_log"`
+	HistLog        string `json:"write_hist_log"`
+	LogOffset      string `json:"log_offset"`
+	LogHistMsec    string `json:"log_hist_msec"`
+}
+
+// GlobalOptions is a struct for FIO JSON input
+type GlobalOptions = JobOptions
+
+// fioDefaults - fio default values for options which define pattern of job
+var fioDefaults = JobOptions{
+	RW:      "read",
+	BS:      "4k",
+	IODepth: "1",
+	NumJobs: "1",
+	// default of fio on Linux, it is used only for names of patterns and descriptions
+	Ioengine: "psync",
 }
 
 // fioJSON is a struct for JSON input
@@ -126,7 +165,7 @@ type FioJSON struct {
 type LogFileInfo struct {
 	FilePathInJSON  string      // "/home/puser/results/write-4k-0"
 	FileType        LogFileType // LogFileType is enum for type of log file
-	YName           string      // "MB/s" "msec" "usec" "IOPS"
+	YName           string      // "Bandwidth" "Latency" "IOPS", unit is chosen by values on chart
 	XName		    string      // Time
 	Header          string      // TestName + Description
 	XValues		    []float64   // time in seconds
@@ -139,10 +178,12 @@ type LogFileInfo struct {
 	DirForImage     string
 	ImgName         string
 	Description     string
+	Units           units.System // units of latency and bandwidth on Y axis
 }
 
 type TestInfo struct {
 	TestName     string
+	Group        string // name of test which results are repetitions of, empty for single run
 	JSONResults  FioJSON
 	LogDirectory string
 	CSVFileName  string
@@ -155,13 +196,172 @@ type AllTestInfo struct {
 	Description        string
 	PathWithSrcResults string
 	ImgFormat          string
+	Inputs             []InputFile
+	Units              units.System // units of latency and bandwidth in charts
+}
+
+// String returns name of direction as it is used in reports
+func (d Direction) String() string {
+	switch d {
+	case DIR_READ:
+		return "read"
+	case DIR_WRITE:
+		return "write"
+	case DIR_TRIM:
+		return "trim"
+	}
+	return "unknown"
+}
+
+// JobDirections returns directions which are used by fio rw pattern
+// (Ex. "randread" -> [read], "randrw" -> [read write])
+func JobDirections(rw string) []Direction {
+	// rw may have sequence suffix like "randread:8"
+	switch strings.Split(rw, ":")[0] {
+	case "read", "randread":
+		return []Direction{DIR_READ}
+	case "rw", "readwrite", "randrw":
+		return []Direction{DIR_READ, DIR_WRITE}
+	case "trim", "randtrim":
+		return []Direction{DIR_TRIM}
+	case "trimwrite", "randtrimwrite":
+		return []Direction{DIR_TRIM, DIR_WRITE}
+	}
+	return []Direction{DIR_WRITE}
+}
+
+// IsMixed returns true if fio rw pattern has more than one direction
+func IsMixed(rw string) bool {
+	return len(JobDirections(rw)) > 1
+}
+
+// inheritOptions - fills empty options of "to" with values from "from"
+func inheritOptions(to *JobOptions, from *JobOptions) {
+	toValue := reflect.ValueOf(to).Elem()
+	fromValue := reflect.ValueOf(from).Elem()
+	for i := 0; i < toValue.NumField(); i++ {
+		field := toValue.Field(i)
+		if field.Kind() == reflect.String && field.String() == "" {
+			field.SetString(fromValue.Field(i).String())
+		}
+	}
+}
+
+// EffectiveOptions returns options of job with values inherited
+// from global options and fio defaults for the options which define pattern
+func (j *Jobs) EffectiveOptions(global *GlobalOptions) JobOptions {
+	options := j.TestOption
+	if global != nil {
+		inheritOptions(&options, global)
+	}
+	inheritOptions(&options, &fioDefaults)
+
+	if IsMixed(options.RW) && options.RWMixRead == "" {
+		options.RWMixRead = "50"
+		if mixWrite, err := strconv.Atoi(options.RWMixWrite); err == nil {
+			options.RWMixRead = strconv.Itoa(100 - mixWrite)
+		}
+	}
+	return options
+}
+
+// Operation returns results of job for direction
+func (j *Jobs) Operation(d Direction) *OperationRW {
+	switch d {
+	case DIR_READ:
+		return &j.Read
+	case DIR_TRIM:
+		return &j.Trim
+	default:
+		return &j.Write
+	}
+}
+
+// LatencyBins returns latency distribution from json+ output as latency in ns -> count of IOs.
+// Returns empty map if results were not written in json+ format.
+// IOs faster than resolution of clock (bin "0") are counted in bin of 1 ns,
+// so distributions can be drawn with log scale of latency
+func (l *LatNS) LatencyBins() map[int64]int64 {
+	bins := make(map[int64]int64, len(l.Bins))
+	for latency, count := range l.Bins {
+		value, err := strconv.ParseInt(latency, 10, 64)
+		if err != nil || count == 0 {
+			continue
+		}
+		if value < 1 {
+			value = 1
+		}
+		bins[value] += count
+	}
+	return bins
+}
+
+// BinsPercentile returns percentile of latency distribution from LatencyBins in the same way as fio:
+// the smallest latency of bins for which the share of IOs with less or equal latency is at least percentile.
+// Returns false if distribution is empty
+func BinsPercentile(bins map[int64]int64, percentile float64) (int64, bool) {
+	var latencies []int64
+	var total int64
+	for latency, count := range bins {
+		latencies = append(latencies, latency)
+		total += count
+	}
+	if total == 0 {
+		return 0, false
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	var sum int64
+	for _, latency := range latencies {
+		sum += bins[latency]
+		if float64(sum) >= percentile/100*float64(total) {
+			return latency, true
+		}
+	}
+	return latencies[len(latencies)-1], true
+}
+
+// Latency returns latency of operation by its name in fio results: slat, clat or lat
+func (o *OperationRW) Latency(name string) (*LatNS, error) {
+	switch name {
+	case "slat":
+		return &o.SlatNS, nil
+	case "clat":
+		return &o.ClatNS, nil
+	case "lat":
+		return &o.LatNS, nil
+	}
+	return nil, fmt.Errorf("unknown type of latency: %s", name)
+}
+
+// PercentileValue returns value of percentile in ns (Ex. 99.9 -> Percentile["99.900000"]).
+// Returns false if fio did not report this percentile.
+func (l *LatNS) PercentileValue(percentile float64) (int64, bool) {
+	value, ok := l.Percentile[fmt.Sprintf("%f", percentile)]
+	return value, ok
+}
+
+// FileName returns name of pattern, test or job which can be used as name of file or dir:
+// separators of paths and characters which are not allowed in file names on Windows are replaced by "_"
+// (Ex. "rand/read-4k" -> "rand_read-4k")
+func FileName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if r < ' ' || strings.ContainsRune(`/\:*?"<>|`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+	// empty name and names of current and parent dirs
+	if strings.Trim(name, ".") == "" {
+		return "_" + name
+	}
+	return name
 }
 
 // CleanJSON removes all another fields from JSON input
 func CleanJSON(in []byte) ([]byte, error) {
 	var begin = bytes.IndexAny(in, "{")
 	var end = bytes.LastIndexAny(in, "}") + 1
-	if begin >= end {
+	if begin < 0 || begin >= end {
 		return nil, fmt.Errorf("incorrect input format in cleanJSON")
 	}
 	return in[begin:end], nil
@@ -176,63 +376,78 @@ func ParseJSON(in []byte) (FioJSON, error) {
 	return data, nil
 }
 
-// checkFolderWithJSONfiles - check if folder with JSON files exists
-func checkFolderWithJSONfiles(pathToJSONfiles string) ([]string, error) {
-	var jsonFilesPath []string
-	files, err := ioutil.ReadDir(pathToJSONfiles)
-	if err != nil {
-		return jsonFilesPath,
-			fmt.Errorf("could not read folder with JSON files: %w", err)
+// ParseFioOutput parses fio results in JSON, terse or normal format.
+// Output of fio with --output-format=normal,json is parsed as JSON,
+// only the first document is returned if there are several JSON documents.
+func ParseFioOutput(in []byte) (FioJSON, error) {
+	if documents, err := DecodeJSONDocuments(in); err == nil {
+		return documents[0], nil
 	}
-
-	for _, file := range files {
-		if file.IsDir() {
-			continue
-		}
-		// Here later it will be possible to write check for the first bytes of
-		// the file, this will get rid of the dependency of the .json extension.
-		if strings.Contains(file.Name(), ".json") {
-			jsonFilesPath = append(jsonFilesPath,
-				filepath.Join(pathToJSONfiles, file.Name()))
+	for _, line := range bytes.Split(in, []byte("\n")) {
+		if IsTerse(line) {
+			return ParseTerse(in)
 		}
 	}
-
-	if len(jsonFilesPath) == 0 {
-		return jsonFilesPath,
-			fmt.Errorf("files with .json extension not found in directory:%s", pathToJSONfiles)
+	data, err := ParseNormal(in)
+	if err != nil {
+		return FioJSON{}, fmt.Errorf("results are neither JSON, terse nor normal fio output: %w", err)
 	}
-
-	return jsonFilesPath, nil
+	return data, nil
 }
 
+// ReadAllJSONFiles - read all files with fio results from catalog,
+// several JSON documents in one file are reported as separate tests
 func ReadAllJSONFiles(catalogWithJSONfiles string) (AllTestInfo, error) {
+	return ReadAllFiles(catalogWithJSONfiles, DOCUMENTS_SPLIT)
+}
+
+// ReadAllFiles - read all files with fio results from catalog. The format of results
+// is detected by content of file, checked files are saved in AllTestInfo.Inputs.
+// If file has several JSON documents, tests are named TestA#1, TestA#2, ...
+// and with DOCUMENTS_MERGE mode they are repetitions of the test TestA
+func ReadAllFiles(catalogWithJSONfiles string, mode DocumentsMode) (AllTestInfo, error) {
 	var allTestInfo AllTestInfo
+	var err error
 
-	jsonFiles, err := checkFolderWithJSONfiles(catalogWithJSONfiles)
+	allTestInfo.Inputs, err = DetectInputFiles(catalogWithJSONfiles)
 	if err != nil {
 		return allTestInfo, err
 	}
 
-	for _, srcFile := range jsonFiles {
-		var testInfo TestInfo
-		data, err := ioutil.ReadFile(srcFile)
-		if err != nil {
-			return allTestInfo, fmt.Errorf("could not read file [%s]: %w", srcFile, err)
+	for i := range allTestInfo.Inputs {
+		input := &allTestInfo.Inputs[i]
+		if !input.Accepted {
+			continue
 		}
-
-		text, err := CleanJSON(data)
+		data, err := ioutil.ReadFile(input.Path)
 		if err != nil {
-			return allTestInfo, fmt.Errorf("could not clean JSON: %w", err)
+			return allTestInfo, fmt.Errorf("could not read file [%s]: %w", input.Path, err)
 		}
 
-		testInfo.JSONResults, err = ParseJSON(text)
+		documents, err := ParseFormat(data, input.Format)
 		if err != nil {
-			return allTestInfo, fmt.Errorf("could not parse JSON: %w", err)
+			return allTestInfo, fmt.Errorf("could not parse file [%s] as fio %s output: %w",
+				input.Path, input.Format, err)
+		}
+		if input.Format == FORMAT_JSON {
+			input.setFormat(DetectJSONPlus(documents))
 		}
 
-		testInfo.TestName = strings.TrimSuffix(filepath.Base(srcFile), ".json")
-		allTestInfo.Tests = append(allTestInfo.Tests, testInfo)
+		testName := strings.TrimSuffix(filepath.Base(input.Path), filepath.Ext(input.Path))
+		for index, document := range documents {
+			testInfo := TestInfo{TestName: testName, JSONResults: document}
+			if len(documents) > 1 {
+				testInfo.TestName = DocumentName(testName, index)
+				if mode == DOCUMENTS_MERGE {
+					testInfo.Group = testName
+				}
+			}
+			allTestInfo.Tests = append(allTestInfo.Tests, testInfo)
+		}
 	}
 
+	if len(allTestInfo.Tests) == 0 {
+		return allTestInfo, fmt.Errorf("files with fio results not found in directory: %s", catalogWithJSONfiles)
+	}
 	return allTestInfo, nil
 }
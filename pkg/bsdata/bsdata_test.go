@@ -0,0 +1,45 @@
+package bsdata
+
+import "testing"
+
+func TestFileName(t *testing.T) {
+	var tests = []struct {
+		name, want string
+	}{
+		{"randread-4k d=32 j=1", "randread-4k d=32 j=1"},
+		{"rand/read-4k", "rand_read-4k"},
+		{`seq\write:1m`, "seq_write_1m"},
+		{"job\t<1>?", "job__1__"},
+		{"..", "_.."},
+		{"", "_"},
+	}
+	for _, test := range tests {
+		if got := FileName(test.name); got != test.want {
+			t.Errorf("FileName(%q) = %q, expected %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestBinsPercentile(t *testing.T) {
+	bins := map[int64]int64{100: 50, 200: 45, 400: 4, 800: 1}
+	var tests = []struct {
+		percentile float64
+		want       int64
+	}{
+		{1, 100},
+		{50, 100},
+		{50.1, 200},
+		{95, 200},
+		{99, 400},
+		{99.5, 800},
+		{100, 800},
+	}
+	for _, test := range tests {
+		if got, ok := BinsPercentile(bins, test.percentile); !ok || got != test.want {
+			t.Errorf("BinsPercentile(p%v) = %d, %v, expected %d", test.percentile, got, ok, test.want)
+		}
+	}
+	if _, ok := BinsPercentile(nil, 99); ok {
+		t.Error("expected no percentile of empty distribution")
+	}
+}
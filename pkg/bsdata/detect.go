@@ -0,0 +1,200 @@
+package bsdata
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+)
+
+// sniffSize - count of first bytes of file which are used for detection of format
+const sniffSize = 64 * 1024
+
+// InputFormat - format of fio results in input file
+type InputFormat int
+
+const (
+	FORMAT_UNKNOWN InputFormat = iota
+	FORMAT_JSON
+	FORMAT_JSON_PLUS
+	FORMAT_TERSE
+	FORMAT_NORMAL
+)
+
+// String returns name of format as it is used in table of input files
+func (f InputFormat) String() string {
+	switch f {
+	case FORMAT_JSON:
+		return "json"
+	case FORMAT_JSON_PLUS:
+		return "json+"
+	case FORMAT_TERSE:
+		return "terse"
+	case FORMAT_NORMAL:
+		return "normal"
+	}
+	return "unknown"
+}
+
+// InputFile - file from catalog with results and decision about it
+type InputFile struct {
+	Path     string
+	Format   InputFormat
+	Accepted bool
+	Reason   string
+}
+
+// editorTemporary - suffixes and prefixes of backup and temporary files of editors
+var (
+	editorTemporarySuffixes = []string{"~", ".swp", ".swo", ".swx", ".bak", ".orig", ".tmp"}
+	editorTemporaryPrefixes = []string{"#", ".#"}
+)
+
+// isEditorTemporary - returns true for files like "TestA.json~", ".TestA.json.swp" or "#TestA.json#"
+func isEditorTemporary(name string) bool {
+	for _, suffix := range editorTemporarySuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	for _, prefix := range editorTemporaryPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// DetectFormat returns format of fio results by the first bytes of output.
+// json+ output is detected as FORMAT_JSON, latency bins of large outputs can be far from
+// the beginning, so json+ is recognized by DetectJSONPlus after decoding
+func DetectFormat(head []byte) InputFormat {
+	if begin := bytes.Index(head, []byte("{")); begin >= 0 &&
+		bytes.Contains(head[begin:], []byte(`"fio version"`)) {
+		return FORMAT_JSON
+	}
+	for _, line := range bytes.Split(head, []byte("\n")) {
+		if IsTerse(line) {
+			return FORMAT_TERSE
+		}
+		if reNormalJobHeader.Match(line) {
+			return FORMAT_NORMAL
+		}
+	}
+	return FORMAT_UNKNOWN
+}
+
+// DetectJSONPlus returns FORMAT_JSON_PLUS if any job of decoded JSON documents has latency bins
+func DetectJSONPlus(documents []FioJSON) InputFormat {
+	for _, document := range documents {
+		for i := range document.Jobs {
+			for d := Direction(0); d < DIR_MAX; d++ {
+				op := document.Jobs[i].Operation(d)
+				if len(op.ClatNS.Bins) != 0 || len(op.LatNS.Bins) != 0 || len(op.SlatNS.Bins) != 0 {
+					return FORMAT_JSON_PLUS
+				}
+			}
+		}
+	}
+	return FORMAT_JSON
+}
+
+// setFormat - set format of accepted input file and reason of decision
+func (input *InputFile) setFormat(format InputFormat) {
+	input.Format = format
+	input.Reason = fmt.Sprintf("fio %s output", format)
+}
+
+// readHead - read the first bytes of file
+func readHead(path string) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	head := make([]byte, sniffSize)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, err
+	}
+	return head[:n], nil
+}
+
+// DetectInputFiles - check every file in catalog and decide whether it has fio results
+func DetectInputFiles(catalog string) ([]InputFile, error) {
+	var inputs []InputFile
+	files, err := ioutil.ReadDir(catalog)
+	if err != nil {
+		return inputs, fmt.Errorf("could not read folder with results: %w", err)
+	}
+
+	for _, file := range files {
+		if file.IsDir() {
+			continue
+		}
+		input := InputFile{Path: filepath.Join(catalog, file.Name())}
+		switch {
+		case isEditorTemporary(file.Name()):
+			input.Reason = "editor temporary or backup file"
+		case strings.HasPrefix(file.Name(), "."):
+			input.Reason = "hidden file"
+		case file.Size() == 0:
+			input.Reason = "empty file"
+		default:
+			head, err := readHead(input.Path)
+			if err != nil {
+				input.Reason = fmt.Sprintf("could not read file: %v", err)
+				break
+			}
+			format := DetectFormat(head)
+			if format == FORMAT_UNKNOWN {
+				input.Reason = "no fio results found"
+				break
+			}
+			input.Accepted = true
+			input.setFormat(format)
+		}
+		inputs = append(inputs, input)
+	}
+	return inputs, nil
+}
+
+// PrintInputFiles - print table of accepted and rejected input files
+func PrintInputFiles(w io.Writer, inputs []InputFile) {
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "FILE\tSTATUS\tREASON")
+	for _, input := range inputs {
+		status := "rejected"
+		if input.Accepted {
+			status = "accepted"
+		}
+		fmt.Fprintf(table, "%s\t%s\t%s\n", filepath.Base(input.Path), status, input.Reason)
+	}
+	table.Flush()
+}
+
+// ParseFormat parses fio results of detected format. JSON output can have several documents,
+// results in other formats are one document
+func ParseFormat(in []byte, format InputFormat) ([]FioJSON, error) {
+	var document FioJSON
+	var err error
+	switch format {
+	case FORMAT_JSON, FORMAT_JSON_PLUS:
+		return DecodeJSONDocuments(in)
+	case FORMAT_TERSE:
+		document, err = ParseTerse(in)
+	case FORMAT_NORMAL:
+		document, err = ParseNormal(in)
+	default:
+		document, err = ParseFioOutput(in)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return []FioJSON{document}, nil
+}
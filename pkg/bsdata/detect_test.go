@@ -0,0 +1,47 @@
+package bsdata
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestReadAllFilesJSONPlus - json+ output is recognized by latency bins of any job,
+// even if they are far from the beginning of file
+func TestReadAllFilesJSONPlus(t *testing.T) {
+	dir := t.TempDir()
+	for name, bins := range map[string]bool{"TestA.json": false, "TestB.json": true} {
+		fio := FioJSON{FioVersion: "fio-3.28"}
+		for i := 0; i < 200; i++ {
+			fio.Jobs = append(fio.Jobs, Jobs{TestName: fmt.Sprintf("randread-4k-%d", i),
+				TestOption: JobOptions{RW: "randread", BS: "4k"}})
+		}
+		if bins {
+			fio.Jobs[len(fio.Jobs)-1].Read.ClatNS.Bins = map[string]int64{"1000": 1}
+		}
+		content, err := json.MarshalIndent(fio, "", "  ")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(content) <= sniffSize {
+			t.Fatalf("output of %s has to be larger than %d bytes, got %d", name, sniffSize, len(content))
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), content, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	results, err := ReadAllFiles(dir, DOCUMENTS_SPLIT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	formats := map[string]InputFormat{"TestA.json": FORMAT_JSON, "TestB.json": FORMAT_JSON_PLUS}
+	for _, input := range results.Inputs {
+		want := formats[filepath.Base(input.Path)]
+		if !input.Accepted || input.Format != want || input.Reason != fmt.Sprintf("fio %s output", want) {
+			t.Errorf("%s: format %s (%s), expected %s", filepath.Base(input.Path), input.Format, input.Reason, want)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package bsdata
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// DocumentsMode - how to report several fio JSON documents from one file
+type DocumentsMode int
+
+const (
+	DOCUMENTS_SPLIT DocumentsMode = iota // separate test for each document: TestA#1, TestA#2, ...
+	DOCUMENTS_MERGE                      // documents are repetitions of one test
+)
+
+// ParseDocumentsMode returns mode by name: split or merge
+func ParseDocumentsMode(name string) (DocumentsMode, error) {
+	switch name {
+	case "split":
+		return DOCUMENTS_SPLIT, nil
+	case "merge":
+		return DOCUMENTS_MERGE, nil
+	}
+	return DOCUMENTS_SPLIT, fmt.Errorf("unknown mode for several JSON documents in file: %s", name)
+}
+
+// DocumentName returns name of test for document with index (from 0) when file has several documents
+func DocumentName(testName string, index int) string {
+	return fmt.Sprintf("%s#%d", testName, index+1)
+}
+
+// DecodeJSONDocuments returns all top-level fio JSON objects from input.
+// Input can have several concatenated fio runs and text of normal output between them.
+// fio writes top-level object from the beginning of line, so only such braces are checked
+// and they are skipped if they are not beginning of fio JSON object.
+func DecodeJSONDocuments(in []byte) ([]FioJSON, error) {
+	var documents []FioJSON
+	var lastErr error
+	for pos := 0; pos < len(in); {
+		begin := bytes.IndexByte(in[pos:], '{')
+		if begin < 0 {
+			break
+		}
+		pos += begin
+		if pos > 0 && in[pos-1] != '\n' {
+			pos++
+			continue
+		}
+
+		var document FioJSON
+		decoder := json.NewDecoder(bytes.NewReader(in[pos:]))
+		if err := decoder.Decode(&document); err != nil || document.FioVersion == "" {
+			if err != nil {
+				lastErr = err
+			}
+			pos++
+			continue
+		}
+		documents = append(documents, document)
+		pos += int(decoder.InputOffset())
+	}
+
+	if len(documents) == 0 {
+		if lastErr != nil {
+			return nil, fmt.Errorf("fio JSON results not found: %w", lastErr)
+		}
+		return nil, fmt.Errorf("fio JSON results not found")
+	}
+	return documents, nil
+}
@@ -0,0 +1,486 @@
+package bsdata
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Regular expressions for lines of fio normal (human-readable) output
+var (
+	// write-64k: (g=0): rw=write, bs=(R) 64.0KiB-64.0KiB, (W) 64.0KiB-64.0KiB, (T) 64.0KiB-64.0KiB, ioengine=libaio, iodepth=8
+	reNormalJobDef = regexp.MustCompile(`^(\S.*?): \(g=(\d+)\): rw=([\w:]+), bs=(.*?), ioengine=(\S+), iodepth=(\d+)`)
+	// write-64k: (groupid=0, jobs=1): err= 0: pid=1234: Fri Apr  1 06:30:43 2022
+	reNormalJobHeader = regexp.MustCompile(`^(\S.*?): \(groupid=(\d+), jobs=(\d+)\): err=\s*(\d+)(?:.*?pid=\d+: (.*))?`)
+	// write: IOPS=3420, BW=214MiB/s (224MB/s)(12.5GiB/60001msec)
+	reNormalDirection = regexp.MustCompile(`^\s+(read|write|trim)\s*: IOPS=([\d.]+[kMGT]?), BW=([\d.]+[KMGTP]?i?B)/s.*?\(([\d.]+[KMGTP]?i?B)/(\d+)msec\)`)
+	// read : io=1024.0MB, bw=17467KB/s, iops=4366, runt= 60031msec (fio 2.x)
+	reNormalDirectionOld = regexp.MustCompile(`^\s+(read|write|trim)\s*: io=([\d.]+[KMGTP]?i?B), bw=([\d.]+[KMGTP]?i?B)/s, iops=([\d.]+[kMGT]?), runt=\s*(\d+)msec`)
+	// clat (usec): min=150, max=67976, avg=2325.40, stdev=1219.77
+	reNormalLatency = regexp.MustCompile(`^\s+(slat|clat|lat)\s*\((nsec|usec|msec|sec)\): min=\s*([\d.]+[kMGT]?), max=\s*([\d.]+[kMGT]?), avg=\s*([\d.]+[kMGT]?), stdev=\s*([\d.]+[kMGT]?)`)
+	// clat percentiles (usec):
+	reNormalPercentiles = regexp.MustCompile(`^\s+(slat|clat|lat) percentiles \((nsec|usec|msec|sec)\):`)
+	// |  1.00th=[  627],  5.00th=[  906], 10.00th=[ 1123], 20.00th=[ 1418],
+	reNormalPercentile = regexp.MustCompile(`([\d.]+)th=\[\s*([\d.]+[kMGT]?)\]`)
+	// bw (  KiB/s): min=180224, max=250880, per=100.00%, avg=219012.47, stdev=12345.67, samples=120
+	reNormalBw = regexp.MustCompile(`^\s+bw\s*\(\s*([KMGTP]?i?B)\s*/s\)\s*: (.*)`)
+	// iops        : min= 2816, max= 3920, avg=3422.07, stdev=192.90, samples=120
+	reNormalIops = regexp.MustCompile(`^\s+iops\s*: (min=.*)`)
+	// cpu          : usr=2.87%, sys=8.01%, ctx=123456, majf=0, minf=12
+	reNormalCPU = regexp.MustCompile(`^\s+cpu\s*: (.*)`)
+	// issued rwts: total=0,205241,0,0 short=0,0,0,0 dropped=0,0,0,0
+	reNormalIssued = regexp.MustCompile(`^\s+issued rwts: total=([\d,]+) short=([\d,]+) dropped=([\d,]+)`)
+	// issued    : total=r=393248/w=393112/d=0, short=r=0/w=0/d=0, drop=r=0/w=0/d=0 (fio 2.x)
+	reNormalIssuedOld = regexp.MustCompile(`^\s+issued\s*: total=r=(\d+)/w=(\d+)/d=(\d+), short=r=(\d+)/w=(\d+)/d=(\d+), drop=r=(\d+)/w=(\d+)/d=(\d+)`)
+	// latency   : target=0, window=0, percentile=100.00%, depth=8
+	reNormalLatencyTarget = regexp.MustCompile(`^\s+latency\s*: (target=.*)`)
+	// Run status group 0 (all jobs):
+	reNormalGroup = regexp.MustCompile(`^Run status group (\d+)`)
+	// WRITE: bw=214MiB/s (224MB/s), 214MiB/s-214MiB/s (224MB/s-224MB/s), io=12.5GiB (13.4GB), run=60001-60001msec
+	reNormalGroupDirection = regexp.MustCompile(`^\s+(READ|WRITE|TRIM)\s*: .*?io=([\d.]+[KMGTP]?i?B).*?run=\s*(\d+)-(\d+)msec`)
+	// nvme0n1: ios=0/204800, merge=0/0, ticks=0/470000, in_queue=470000, util=99.90%
+	reNormalDisk = regexp.MustCompile(`^\s+(\S+): (ios=\d+/\d+.*util=[\d.]+%)`)
+	// fio-3.28
+	reNormalVersion = regexp.MustCompile(`^(fio-\S+)$`)
+)
+
+// latencyUnits - multipliers for converting latency to nanoseconds
+var latencyUnits = map[string]float64{
+	"nsec": 1,
+	"usec": 1000,
+	"msec": 1000 * 1000,
+	"sec":  1000 * 1000 * 1000,
+}
+
+// normalParser - state of parser for fio normal output
+type normalParser struct {
+	fio         FioJSON
+	definitions map[string]JobOptions // options from job definitions by job name
+	job         *Jobs
+	op          *OperationRW
+	percentiles *LatNS
+	latUnit     float64
+	group       int
+	inDiskStats bool
+}
+
+// parseNumber parses number with decimal suffix (Ex. "34.2k" -> 34200)
+func parseNumber(value string) (float64, error) {
+	value = strings.TrimSpace(value)
+	multiplier := 1.0
+	if len(value) != 0 {
+		switch value[len(value)-1] {
+		case 'k', 'K':
+			multiplier = 1000
+		case 'M':
+			multiplier = 1000 * 1000
+		case 'G':
+			multiplier = 1000 * 1000 * 1000
+		case 'T':
+			multiplier = 1000 * 1000 * 1000 * 1000
+		}
+		if multiplier != 1 {
+			value = value[:len(value)-1]
+		}
+	}
+	number, err := strconv.ParseFloat(strings.TrimSuffix(value, "%"), 64)
+	if err != nil {
+		return 0, fmt.Errorf("incorrect number [%s]: %w", value, err)
+	}
+	return number * multiplier, nil
+}
+
+// parseSize parses size with units (Ex. "64.0KiB" -> 65536, "224MB" -> 224000000)
+func parseSize(value string) (float64, error) {
+	units := []struct {
+		suffix     string
+		multiplier float64
+	}{
+		{"PiB", math.Pow(1024, 5)}, {"TiB", math.Pow(1024, 4)}, {"GiB", math.Pow(1024, 3)},
+		{"MiB", math.Pow(1024, 2)}, {"KiB", 1024},
+		{"PB", math.Pow(1000, 5)}, {"TB", math.Pow(1000, 4)}, {"GB", math.Pow(1000, 3)},
+		{"MB", math.Pow(1000, 2)}, {"KB", 1000}, {"B", 1},
+	}
+	value = strings.TrimSpace(value)
+	for _, unit := range units {
+		if strings.HasSuffix(value, unit.suffix) {
+			number, err := strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(value, unit.suffix)), 64)
+			if err != nil {
+				return 0, fmt.Errorf("incorrect size [%s]: %w", value, err)
+			}
+			return number * unit.multiplier, nil
+		}
+	}
+	return parseNumber(value)
+}
+
+// FormatBlockSize formats size in bytes as fio block size (Ex. 65536 -> "64k")
+func FormatBlockSize(bytes int64) string {
+	switch {
+	case bytes >= 1024*1024 && bytes%(1024*1024) == 0:
+		return fmt.Sprintf("%dm", bytes/(1024*1024))
+	case bytes >= 1024 && bytes%1024 == 0:
+		return fmt.Sprintf("%dk", bytes/1024)
+	}
+	return fmt.Sprintf("%d", bytes)
+}
+
+// keyValues parses list of "key=value" separated by commas
+func keyValues(line string) map[string]string {
+	values := make(map[string]string)
+	for _, field := range strings.Split(line, ",") {
+		pair := strings.SplitN(field, "=", 2)
+		if len(pair) == 2 {
+			values[strings.TrimSpace(pair[0])] = strings.TrimSpace(pair[1])
+		}
+	}
+	return values
+}
+
+// intValue - value from keyValues as int, 0 if it is missing
+func intValue(values map[string]string, key string) int {
+	number, _ := parseNumber(values[key])
+	return int(number)
+}
+
+// floatValue - value from keyValues as float64, 0 if it is missing
+func floatValue(values map[string]string, key string) float64 {
+	number, _ := parseNumber(values[key])
+	return number
+}
+
+// definitionBlockSize - block size for direction from job definition
+// (Ex. "(R) 64.0KiB-64.0KiB, (W) 64.0KiB-64.0KiB, (T) 64.0KiB-64.0KiB"
+// or "64K-64K/64K-64K/64K-64K" of fio 2.x with sizes of read, write and trim)
+func definitionBlockSize(definition string, rw string) string {
+	var letters = map[Direction]string{DIR_READ: "R", DIR_WRITE: "W", DIR_TRIM: "T"}
+	sizes := make(map[string]string)
+	if !strings.Contains(definition, "(") {
+		for d, field := range strings.Split(definition, "/") {
+			rangeBs := strings.SplitN(strings.TrimSpace(field), "-", 2)
+			size, err := parseSize(binaryUnits(rangeBs[0] + "B"))
+			if err != nil || Direction(d) >= DIR_MAX {
+				continue
+			}
+			sizes[letters[Direction(d)]] = FormatBlockSize(int64(size))
+		}
+	}
+	for _, field := range strings.Split(definition, ",") {
+		field = strings.TrimSpace(field)
+		if len(field) < 4 || field[0] != '(' {
+			continue
+		}
+		rangeBs := strings.SplitN(strings.TrimSpace(field[3:]), "-", 2)
+		size, err := parseSize(rangeBs[0])
+		if err != nil {
+			continue
+		}
+		sizes[field[1:2]] = FormatBlockSize(int64(size))
+	}
+
+	var result []string
+	for _, d := range JobDirections(rw) {
+		if size, ok := sizes[letters[d]]; ok && (len(result) == 0 || result[0] != size) {
+			result = append(result, size)
+		}
+	}
+	return strings.Join(result, ",")
+}
+
+// startJob - begin of job results
+func (p *normalParser) startJob(match []string) {
+	p.fio.Jobs = append(p.fio.Jobs, Jobs{})
+	p.job = &p.fio.Jobs[len(p.fio.Jobs)-1]
+	p.op = nil
+	p.percentiles = nil
+	p.job.TestName = match[1]
+	p.job.GroupID, _ = strconv.Atoi(match[2])
+	p.job.Error, _ = strconv.Atoi(match[4])
+	p.job.TestOption = p.definitions[match[1]]
+	p.job.TestOption.NumJobs = match[3]
+	if match[5] != "" && p.fio.Time == "" {
+		p.fio.Time = strings.TrimSpace(match[5])
+	}
+}
+
+// setDirection - begin of results for direction of current job
+func (p *normalParser) setDirection(name string, iops, bw, io, runtime string) error {
+	d := map[string]Direction{"read": DIR_READ, "write": DIR_WRITE, "trim": DIR_TRIM}[name]
+	p.op = p.job.Operation(d)
+	p.percentiles = nil
+
+	iopsValue, err := parseNumber(iops)
+	if err != nil {
+		return err
+	}
+	bwValue, err := parseSize(bw)
+	if err != nil {
+		return err
+	}
+	ioValue, err := parseSize(io)
+	if err != nil {
+		return err
+	}
+	p.op.Iops = iopsValue
+	p.op.Bw = int(bwValue / 1024)
+	p.op.IoBytes = int64(ioValue)
+	p.op.IoKbytes = int(ioValue / 1024)
+	p.op.Runtime, _ = strconv.Atoi(runtime)
+	return nil
+}
+
+// setLatency - min/max/avg/stdev of latency for current direction
+func (p *normalParser) setLatency(match []string) {
+	latNS, _ := p.op.Latency(match[1])
+	unit := latencyUnits[match[2]]
+	min, _ := parseNumber(match[3])
+	max, _ := parseNumber(match[4])
+	avg, _ := parseNumber(match[5])
+	stdev, _ := parseNumber(match[6])
+	latNS.Min = int64(min * unit)
+	latNS.Max = int64(max * unit)
+	latNS.Mean = avg * unit
+	latNS.Stddev = stdev * unit
+}
+
+// setPercentiles - values from line of percentiles block
+func (p *normalParser) setPercentiles(line string) {
+	for _, match := range reNormalPercentile.FindAllStringSubmatch(line, -1) {
+		percentile, err := strconv.ParseFloat(match[1], 64)
+		if err != nil {
+			continue
+		}
+		value, err := parseNumber(match[2])
+		if err != nil {
+			continue
+		}
+		if p.percentiles.Percentile == nil {
+			p.percentiles.Percentile = make(map[string]int64)
+		}
+		p.percentiles.Percentile[fmt.Sprintf("%f", percentile)] = int64(value * p.latUnit)
+	}
+}
+
+// setGroupResults - fills runtime and io of jobs from run status group when they are missing
+func (p *normalParser) setGroupResults(match []string) {
+	d := map[string]Direction{"READ": DIR_READ, "WRITE": DIR_WRITE, "TRIM": DIR_TRIM}[match[1]]
+	io, _ := parseSize(match[2])
+	runtime, _ := strconv.Atoi(match[4])
+
+	var groupJobs []*Jobs
+	for i := range p.fio.Jobs {
+		job := &p.fio.Jobs[i]
+		if job.GroupID != p.group {
+			continue
+		}
+		for _, jobDirection := range JobDirections(job.TestOption.RW) {
+			if jobDirection == d {
+				groupJobs = append(groupJobs, job)
+			}
+		}
+	}
+	for _, job := range groupJobs {
+		op := job.Operation(d)
+		if op.Runtime == 0 {
+			op.Runtime = runtime
+		}
+		// io of group can be used only if there is one job in group
+		if op.IoBytes == 0 && len(groupJobs) == 1 {
+			op.IoBytes = int64(io)
+			op.IoKbytes = int(io / 1024)
+		}
+	}
+}
+
+// setIssued - counts of issued IOs of job for read, write and trim
+func (p *normalParser) setIssued(total, short, dropped []string) {
+	for d := Direction(0); d < DIR_MAX; d++ {
+		op := p.job.Operation(d)
+		if int(d) < len(total) {
+			op.TotalIos, _ = strconv.Atoi(total[d])
+		}
+		if int(d) < len(short) {
+			op.ShortIos, _ = strconv.Atoi(short[d])
+		}
+		if int(d) < len(dropped) {
+			op.DropIos, _ = strconv.Atoi(dropped[d])
+		}
+	}
+}
+
+// binaryUnits - fio 2.x writes binary units as "KB", "MB" (Ex. "17467KB" -> "17467KiB")
+func binaryUnits(size string) string {
+	if strings.HasSuffix(size, "B") && !strings.HasSuffix(size, "iB") && len(size) > 1 &&
+		strings.ContainsAny(size[len(size)-2:len(size)-1], "KMGTP") {
+		return size[:len(size)-1] + "iB"
+	}
+	return size
+}
+
+// parseLine - parse one line of fio normal output
+func (p *normalParser) parseLine(line string) error {
+	if match := reNormalVersion.FindStringSubmatch(line); match != nil {
+		p.fio.FioVersion = match[1]
+		return nil
+	}
+	if match := reNormalJobDef.FindStringSubmatch(line); match != nil {
+		p.definitions[match[1]] = JobOptions{
+			RW:       match[3],
+			BS:       definitionBlockSize(match[4], match[3]),
+			Ioengine: match[5],
+			IODepth:  match[6],
+		}
+		return nil
+	}
+	if match := reNormalJobHeader.FindStringSubmatch(line); match != nil {
+		p.startJob(match)
+		p.inDiskStats = false
+		return nil
+	}
+	if match := reNormalGroup.FindStringSubmatch(line); match != nil {
+		p.group, _ = strconv.Atoi(match[1])
+		p.job = nil
+		return nil
+	}
+	if strings.HasPrefix(line, "Disk stats") {
+		p.inDiskStats = true
+		p.job = nil
+		return nil
+	}
+	if p.inDiskStats {
+		if match := reNormalDisk.FindStringSubmatch(line); match != nil {
+			p.fio.DiskUtil = append(p.fio.DiskUtil, diskUtil(match[1], match[2]))
+		}
+		return nil
+	}
+	if p.job == nil {
+		if match := reNormalGroupDirection.FindStringSubmatch(line); match != nil {
+			p.setGroupResults(match)
+		}
+		return nil
+	}
+
+	if match := reNormalDirection.FindStringSubmatch(line); match != nil {
+		return p.setDirection(match[1], match[2], match[3], match[4], match[5])
+	}
+	if match := reNormalDirectionOld.FindStringSubmatch(line); match != nil {
+		return p.setDirection(match[1], match[4], binaryUnits(match[3]), binaryUnits(match[2]), match[5])
+	}
+	if match := reNormalCPU.FindStringSubmatch(line); match != nil {
+		values := keyValues(match[1])
+		p.job.UsrCPU = floatValue(values, "usr")
+		p.job.SysCPU = floatValue(values, "sys")
+		p.job.Ctx = intValue(values, "ctx")
+		p.job.Majf = intValue(values, "majf")
+		p.job.Minf = intValue(values, "minf")
+		p.op = nil
+		return nil
+	}
+	if match := reNormalIssued.FindStringSubmatch(line); match != nil {
+		p.setIssued(strings.Split(match[1], ","), strings.Split(match[2], ","), strings.Split(match[3], ","))
+		return nil
+	}
+	if match := reNormalIssuedOld.FindStringSubmatch(line); match != nil {
+		p.setIssued(match[1:4], match[4:7], match[7:10])
+		return nil
+	}
+	if match := reNormalLatencyTarget.FindStringSubmatch(line); match != nil {
+		values := keyValues(match[1])
+		p.job.LatencyTarget = intValue(values, "target")
+		p.job.LatencyWindow = intValue(values, "window")
+		p.job.LatencyPercentile = floatValue(values, "percentile")
+		p.job.LatencyDepth = intValue(values, "depth")
+		return nil
+	}
+	if p.op == nil {
+		return nil
+	}
+
+	if match := reNormalLatency.FindStringSubmatch(line); match != nil {
+		p.setLatency(match)
+		p.percentiles = nil
+		return nil
+	}
+	if match := reNormalPercentiles.FindStringSubmatch(line); match != nil {
+		p.percentiles, _ = p.op.Latency(match[1])
+		p.latUnit = latencyUnits[match[2]]
+		return nil
+	}
+	if p.percentiles != nil && strings.HasPrefix(strings.TrimSpace(line), "|") {
+		p.setPercentiles(line)
+		return nil
+	}
+	p.percentiles = nil
+
+	if match := reNormalBw.FindStringSubmatch(line); match != nil {
+		unit, _ := parseSize("1" + binaryUnits(match[1]))
+		values := keyValues(match[2])
+		p.op.BwMin = int(floatValue(values, "min") * unit / 1024)
+		p.op.BwMax = int(floatValue(values, "max") * unit / 1024)
+		p.op.BwAgg = floatValue(values, "per")
+		p.op.BwMean = floatValue(values, "avg") * unit / 1024
+		p.op.BwDev = floatValue(values, "stdev") * unit / 1024
+		p.op.BwSamples = intValue(values, "samples")
+		return nil
+	}
+	if match := reNormalIops.FindStringSubmatch(line); match != nil {
+		values := keyValues(match[1])
+		p.op.IopsMin = intValue(values, "min")
+		p.op.IopsMax = intValue(values, "max")
+		p.op.IopsMean = floatValue(values, "avg")
+		p.op.IopsStddev = floatValue(values, "stdev")
+		p.op.IopsSamples = intValue(values, "samples")
+	}
+	return nil
+}
+
+// diskUtil - disk utilization from line of disk stats
+func diskUtil(name, line string) DiskUtil {
+	pair := func(value string) (int64, int64) {
+		values := strings.SplitN(value, "/", 2)
+		if len(values) != 2 {
+			return 0, 0
+		}
+		first, _ := strconv.ParseInt(values[0], 10, 64)
+		second, _ := strconv.ParseInt(values[1], 10, 64)
+		return first, second
+	}
+	values := keyValues(line)
+	readIos, writeIos := pair(values["ios"])
+	readMerges, writeMerges := pair(values["merge"])
+	readTicks, writeTicks := pair(values["ticks"])
+	return DiskUtil{
+		Name:        name,
+		ReadIos:     int(readIos),
+		WriteIos:    int(writeIos),
+		ReadMerges:  int(readMerges),
+		WriteMerges: int(writeMerges),
+		ReadTicks:   readTicks,
+		WriteTicks:  writeTicks,
+		InQueue:     int64(floatValue(values, "in_queue")),
+		Util:        floatValue(values, "util"),
+	}
+}
+
+// ParseNormal parses fio normal (human-readable) output into the same model as FIO JSON
+func ParseNormal(in []byte) (FioJSON, error) {
+	parser := normalParser{definitions: make(map[string]JobOptions)}
+	scanner := bufio.NewScanner(bytes.NewReader(in))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		if err := parser.parseLine(strings.TrimRight(scanner.Text(), "\r")); err != nil {
+			return FioJSON{}, fmt.Errorf("could not parse line %d of fio output: %w", lineNumber, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return FioJSON{}, fmt.Errorf("could not read fio output: %w", err)
+	}
+	if len(parser.fio.Jobs) == 0 {
+		return FioJSON{}, fmt.Errorf("results of jobs not found in fio normal output")
+	}
+	return parser.fio, nil
+}
@@ -0,0 +1,255 @@
+package bsdata
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// wantOperation - expected results of one direction of job, latencies in ns, bandwidth in KiB/s
+type wantOperation struct {
+	iops        float64
+	bw          int
+	ioBytes     int64
+	runtime     int
+	slat        [3]float64 // min, max, mean
+	clat        [3]float64
+	lat         [3]float64
+	percentiles map[string]int64 // clat percentiles
+	bwMin       int
+	bwMax       int
+	bwMean      float64
+	iopsMin     int
+	iopsMax     int
+	totalIos    int
+}
+
+// wantJob - expected results of job
+type wantJob struct {
+	name    string
+	group   int
+	options JobOptions
+	usrCPU  float64
+	ctx     int
+	depth   int
+	ops     map[Direction]wantOperation
+}
+
+func approxEqual(a, b float64) bool {
+	return math.Abs(a-b) <= 1e-9*math.Max(math.Abs(a), math.Abs(b))
+}
+
+func checkOperation(t *testing.T, job string, d Direction, op *OperationRW, want wantOperation) {
+	t.Helper()
+	if !approxEqual(op.Iops, want.iops) || op.Bw != want.bw || op.IoBytes != want.ioBytes || op.Runtime != want.runtime {
+		t.Errorf("%s %s: iops=%v bw=%d io=%d runtime=%d, expected iops=%v bw=%d io=%d runtime=%d", job, d,
+			op.Iops, op.Bw, op.IoBytes, op.Runtime, want.iops, want.bw, want.ioBytes, want.runtime)
+	}
+	for name, pair := range map[string]struct {
+		got  LatNS
+		want [3]float64
+	}{"slat": {op.SlatNS, want.slat}, "clat": {op.ClatNS, want.clat}, "lat": {op.LatNS, want.lat}} {
+		got := [3]float64{float64(pair.got.Min), float64(pair.got.Max), pair.got.Mean}
+		for i := range got {
+			if !approxEqual(got[i], pair.want[i]) {
+				t.Errorf("%s %s: %s min/max/mean %v, expected %v", job, d, name, got, pair.want)
+				break
+			}
+		}
+	}
+	for key, value := range want.percentiles {
+		if got, ok := op.ClatNS.Percentile[key]; !ok || got != value {
+			t.Errorf("%s %s: clat percentile %s = %d (found %v), expected %d", job, d, key, got, ok, value)
+		}
+	}
+	// fio reports 17 percentiles by default, none for directions without IO
+	var count int
+	if len(want.percentiles) != 0 {
+		count = 17
+	}
+	if len(op.ClatNS.Percentile) != count {
+		t.Errorf("%s %s: expected %d clat percentiles, got %d", job, d, count, len(op.ClatNS.Percentile))
+	}
+	if op.BwMin != want.bwMin || op.BwMax != want.bwMax || !approxEqual(op.BwMean, want.bwMean) {
+		t.Errorf("%s %s: bw min/max/avg %d/%d/%v, expected %d/%d/%v", job, d,
+			op.BwMin, op.BwMax, op.BwMean, want.bwMin, want.bwMax, want.bwMean)
+	}
+	if op.IopsMin != want.iopsMin || op.IopsMax != want.iopsMax {
+		t.Errorf("%s %s: iops min/max %d/%d, expected %d/%d", job, d, op.IopsMin, op.IopsMax, want.iopsMin, want.iopsMax)
+	}
+	if op.TotalIos != want.totalIos {
+		t.Errorf("%s %s: total IOs %d, expected %d", job, d, op.TotalIos, want.totalIos)
+	}
+}
+
+func checkJobs(t *testing.T, jobs []Jobs, want []wantJob) {
+	t.Helper()
+	if len(jobs) != len(want) {
+		t.Fatalf("expected %d jobs, got %d", len(want), len(jobs))
+	}
+	for i, w := range want {
+		job := &jobs[i]
+		if job.TestName != w.name || job.GroupID != w.group || job.TestOption != w.options {
+			t.Errorf("job %d: %s group %d options %+v, expected %s group %d options %+v", i,
+				job.TestName, job.GroupID, job.TestOption, w.name, w.group, w.options)
+		}
+		if !approxEqual(job.UsrCPU, w.usrCPU) || job.Ctx != w.ctx || job.LatencyDepth != w.depth {
+			t.Errorf("%s: usr=%v ctx=%d depth=%d, expected usr=%v ctx=%d depth=%d", w.name,
+				job.UsrCPU, job.Ctx, job.LatencyDepth, w.usrCPU, w.ctx, w.depth)
+		}
+		for d, op := range w.ops {
+			checkOperation(t, w.name, d, job.Operation(d), op)
+		}
+	}
+}
+
+func TestParseNormal(t *testing.T) {
+	var tests = []struct {
+		file    string
+		version string
+		time    string
+		disk    DiskUtil
+		jobs    []wantJob
+	}{
+		{
+			// fio 3.x: mixed randrw with slat in nsec, trim with percentiles in msec,
+			// sequential write with clat in msec and bandwidth samples in MiB/s
+			file:    "normal-fio3.txt",
+			version: "fio-3.28",
+			time:    "Tue May 10 12:00:01 2022",
+			disk:    DiskUtil{Name: "nvme0n1", ReadIos: 3672201, WriteIos: 7258541, ReadTicks: 1339810, WriteTicks: 2570201, InQueue: 3910011, Util: 99.93},
+			jobs: []wantJob{
+				{
+					name:    "randrw-4k-32",
+					options: JobOptions{RW: "randrw", BS: "4k", Ioengine: "libaio", IODepth: "32", NumJobs: "1"},
+					usrCPU:  12.35, ctx: 2209714, depth: 32,
+					ops: map[Direction]wantOperation{
+						DIR_READ: {
+							iops: 61200, bw: 239 * 1024, ioBytes: 14 << 30, runtime: 60001,
+							slat:        [3]float64{1203, 95120, 2845.31},
+							clat:        [3]float64{48000, 6812000, 367520},
+							lat:         [3]float64{51000, 6815000, 370420},
+							percentiles: map[string]int64{"1.000000": 176000, "50.000000": 355000, "99.000000": 750000, "99.990000": 2540000},
+							bwMin:       228128, bwMax: 256672, bwMean: 244922.40,
+							iopsMin: 57032, iopsMax: 64168, totalIos: 3672480,
+						},
+						DIR_WRITE: {
+							iops: 26200, bw: 102 * 1024, ioBytes: 6148 << 20, runtime: 60001,
+							slat:        [3]float64{1390, 88310, 3120.77},
+							clat:        [3]float64{52000, 7020000, 370110},
+							lat:         [3]float64{55000, 7024000, 373290},
+							percentiles: map[string]int64{"99.000000": 758000, "99.900000": 1254000},
+							bwMin:       97416, bwMax: 110136, bwMean: 104903.33,
+							iopsMin: 24354, iopsMax: 27534, totalIos: 1573888,
+						},
+						DIR_TRIM: {},
+					},
+				},
+				{
+					name:    "trim-64k",
+					options: JobOptions{RW: "randtrim", BS: "64k", Ioengine: "libaio", IODepth: "8", NumJobs: "1"},
+					usrCPU:  0.95, ctx: 204820, depth: 8,
+					ops: map[Direction]wantOperation{
+						DIR_READ:  {},
+						DIR_WRITE: {},
+						DIR_TRIM: {
+							iops: 3412, bw: 213 * 1024, ioBytes: 25 << 29, runtime: 60002,
+							clat:        [3]float64{310000, 18452000, 2337400},
+							lat:         [3]float64{312000, 18455000, 2340050},
+							percentiles: map[string]int64{"1.000000": 2000000, "99.000000": 5000000, "99.990000": 17000000},
+							bwMin:       196608, bwMax: 229376, bwMean: 218450.13,
+							iopsMin: 3072, iopsMax: 3584, totalIos: 204750,
+						},
+					},
+				},
+				{
+					name:    "write-1m",
+					group:   1,
+					options: JobOptions{RW: "write", BS: "1m", Ioengine: "libaio", IODepth: "4", NumJobs: "1"},
+					usrCPU:  8.41, ctx: 113420, depth: 4,
+					ops: map[Direction]wantOperation{
+						DIR_WRITE: {
+							iops: 1890, bw: 1890 * 1024, ioBytes: 111 << 30, runtime: 60003,
+							slat:        [3]float64{18000, 412000, 31450},
+							clat:        [3]float64{1000000, 23000000, 2080000},
+							lat:         [3]float64{1000000, 23000000, 2110000},
+							percentiles: map[string]int64{"99.000000": 3228000, "99.990000": 16057000},
+							bwMin:       1720 * 1024, bwMax: 2010 * 1024, bwMean: 1891.20 * 1024,
+							iopsMin: 1720, iopsMax: 2010, totalIos: 113405,
+						},
+					},
+				},
+			},
+		},
+		{
+			// fio 2.x: "KB" is KiB, block sizes "4K-4K/4K-4K/4K-4K", issued IOs as "r=/w=/d="
+			file:    "normal-fio2.txt",
+			version: "fio-2.2.10",
+			time:    "Mon Jan 11 10:33:12 2016",
+			disk:    DiskUtil{Name: "sda", ReadIos: 393099, WriteIos: 392965, ReadTicks: 672788, WriteTicks: 5588, InQueue: 678420, Util: 99.87},
+			jobs: []wantJob{
+				{
+					name:    "randrw-4k-16",
+					options: JobOptions{RW: "randrw", BS: "4k", Ioengine: "libaio", IODepth: "16", NumJobs: "1"},
+					usrCPU:  3.18, ctx: 402395, depth: 16,
+					ops: map[Direction]wantOperation{
+						DIR_READ: {
+							iops: 6553, bw: 26215, ioBytes: 1610822451 /* 1536.2 MiB */, runtime: 60004,
+							slat:        [3]float64{2000, 262000, 5220},
+							clat:        [3]float64{64000, 82893000, 1723030},
+							lat:         [3]float64{72000, 82897000, 1728440},
+							percentiles: map[string]int64{"1.000000": 199000, "50.000000": 1272000, "99.000000": 9920000, "99.990000": 57088000},
+							bwMin:       20408, bwMax: 31560, bwMean: 26229.60,
+							totalIos: 393248,
+						},
+						DIR_WRITE: {
+							iops: 6551, bw: 26206, ioBytes: 1610193305 /* 1535.6 MiB */, runtime: 60004,
+							slat:        [3]float64{2000, 301000, 5610},
+							clat:        [3]float64{38000, 81766000, 703580},
+							lat:         [3]float64{48000, 81770000, 709390},
+							percentiles: map[string]int64{"99.000000": 5600000},
+							bwMin:       20112, bwMax: 31792, bwMean: 26219.33,
+							totalIos: 393112,
+						},
+					},
+				},
+				{
+					name:    "trim-8k",
+					options: JobOptions{RW: "randtrim", BS: "8k", Ioengine: "libaio", IODepth: "4", NumJobs: "1"},
+					usrCPU:  0.71, ctx: 524301, depth: 4,
+					ops: map[Direction]wantOperation{
+						DIR_TRIM: {
+							iops: 8737, bw: 69898, ioBytes: 4 << 30, runtime: 60006,
+							clat:        [3]float64{1000000, 120000000, 4120000},
+							lat:         [3]float64{1000000, 120000000, 4130000},
+							percentiles: map[string]int64{"99.000000": 10944000, "99.990000": 101888000},
+							bwMin:       52144, bwMax: 80336, bwMean: 69910.25,
+							totalIos: 524288,
+						},
+					},
+				},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.file, func(t *testing.T) {
+			in, err := os.ReadFile(filepath.Join("testdata", test.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			fio, err := ParseNormal(in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fio.FioVersion != test.version || fio.Time != test.time {
+				t.Errorf("version %q time %q, expected %q %q", fio.FioVersion, fio.Time, test.version, test.time)
+			}
+			if len(fio.DiskUtil) != 1 || fio.DiskUtil[0] != test.disk {
+				t.Errorf("disk stats %+v, expected %+v", fio.DiskUtil, test.disk)
+			}
+			checkJobs(t, fio.Jobs, test.jobs)
+		})
+	}
+}
@@ -0,0 +1,166 @@
+package bsdata
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Keys of pattern for sorting
+const (
+	SORT_RW        = "rw"
+	SORT_BS        = "bs"
+	SORT_IODEPTH   = "iodepth"
+	SORT_NUMJOBS   = "numjobs"
+	SORT_DIRECTION = "direction"
+)
+
+// DefaultSortOrder - order of keys for sorting of patterns, direction of mixed jobs is always the last
+var DefaultSortOrder = []string{SORT_RW, SORT_BS, SORT_IODEPTH, SORT_NUMJOBS}
+
+// rwOrder - natural order of fio rw patterns: sequential before random, single direction before mixed
+var rwOrder = []string{"read", "write", "trim", "randread", "randwrite", "randtrim",
+	"rw", "readwrite", "randrw", "trimwrite", "randtrimwrite"}
+
+// directionOrder - order of directions of mixed jobs
+var directionOrder = []string{"", "read", "write", "trim", "total"}
+
+// PatternKey - parts of pattern name which are used for sorting
+type PatternKey struct {
+	RW        string
+	BS        string
+	IODepth   string
+	NumJobs   string
+	Direction string // direction of mixed job, empty for jobs with one direction
+}
+
+// PatternKey - key for sorting of job by options, options must be effective options of job
+func (o JobOptions) PatternKey() PatternKey {
+	return PatternKey{RW: o.RW, BS: o.BS, IODepth: o.IODepth, NumJobs: o.NumJobs}
+}
+
+// ParseSortOrder - parse comma separated list of keys (Ex. "bs,rw,iodepth,numjobs"),
+// keys which are not in list are compared after keys from list in default order
+func ParseSortOrder(order string) ([]string, error) {
+	var keys []string
+	known := map[string]bool{SORT_RW: true, SORT_BS: true, SORT_IODEPTH: true, SORT_NUMJOBS: true, SORT_DIRECTION: true}
+	used := make(map[string]bool)
+	for _, key := range strings.Split(order, ",") {
+		key = strings.ToLower(strings.TrimSpace(key))
+		if key == "" {
+			continue
+		}
+		if !known[key] {
+			return nil, fmt.Errorf("unknown key for sorting of patterns: %s (keys: rw, bs, iodepth, numjobs, direction)", key)
+		}
+		if used[key] {
+			return nil, fmt.Errorf("key for sorting of patterns is repeated: %s", key)
+		}
+		used[key] = true
+		keys = append(keys, key)
+	}
+	for _, key := range append(append([]string{}, DefaultSortOrder...), SORT_DIRECTION) {
+		if !used[key] {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+// BlockSizeBytes - block size of fio option bs in bytes (Ex. "4k" -> 4096, "1m" -> 1048576).
+// The first size is used for lists and ranges (Ex. "4k,64k", "4k-64k" or bssplit "4k/50:64k/50")
+func BlockSizeBytes(bs string) (int64, bool) {
+	value := strings.ToLower(strings.TrimSpace(bs))
+	if index := strings.IndexAny(value, ",-:/"); index >= 0 {
+		value = value[:index]
+	}
+	value = strings.TrimSuffix(strings.TrimSuffix(value, "b"), "i")
+
+	multiplier := 1.0
+	for power, suffix := range []string{"k", "m", "g", "t", "p"} {
+		if strings.HasSuffix(value, suffix) {
+			multiplier = math.Pow(1024, float64(power+1))
+			value = strings.TrimSuffix(value, suffix)
+			break
+		}
+	}
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, false
+	}
+	return int64(number * multiplier), true
+}
+
+// indexIn - index of value in list, length of list for unknown values
+func indexIn(list []string, value string) int {
+	for index, item := range list {
+		if item == value {
+			return index
+		}
+	}
+	return len(list)
+}
+
+// compareInts - compare numbers from strings, numbers are before other strings
+func compareInts(a, b string) int {
+	x, errX := strconv.ParseInt(a, 10, 64)
+	y, errY := strconv.ParseInt(b, 10, 64)
+	switch {
+	case errX == nil && errY == nil:
+		return compareValues(x, y)
+	case errX == nil:
+		return -1
+	case errY == nil:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+func compareValues(x, y int64) int {
+	if x < y {
+		return -1
+	} else if x > y {
+		return 1
+	}
+	return 0
+}
+
+// compareKey - compare patterns by one key
+func compareKey(a, b PatternKey, key string) int {
+	switch key {
+	case SORT_RW:
+		if result := compareValues(int64(indexIn(rwOrder, a.RW)), int64(indexIn(rwOrder, b.RW))); result != 0 {
+			return result
+		}
+		return strings.Compare(a.RW, b.RW)
+	case SORT_BS:
+		x, okX := BlockSizeBytes(a.BS)
+		y, okY := BlockSizeBytes(b.BS)
+		if okX && okY && x != y {
+			return compareValues(x, y)
+		}
+		return strings.Compare(a.BS, b.BS)
+	case SORT_IODEPTH:
+		return compareInts(a.IODepth, b.IODepth)
+	case SORT_NUMJOBS:
+		return compareInts(a.NumJobs, b.NumJobs)
+	case SORT_DIRECTION:
+		return compareValues(int64(indexIn(directionOrder, a.Direction)), int64(indexIn(directionOrder, b.Direction)))
+	}
+	return 0
+}
+
+// ComparePatterns - compare patterns by keys in order, result is -1, 0 or 1.
+// DefaultSortOrder is used if order is empty
+func ComparePatterns(a, b PatternKey, order []string) int {
+	if len(order) == 0 {
+		order = append(append([]string{}, DefaultSortOrder...), SORT_DIRECTION)
+	}
+	for _, key := range order {
+		if result := compareKey(a, b, key); result != 0 {
+			return result
+		}
+	}
+	return 0
+}
@@ -0,0 +1,255 @@
+package bsdata
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Layout of fio terse (--minimal, --output-format=terse) output.
+// terse_version;fio_version;jobname;groupid;error;{read};{write};{trim};cpu;io depths;latency distribution;disks
+// Each direction is: kb;bw;iops;runtime;slat(4);clat(4);clat percentiles(20);lat(4);bw stats(5)
+// and for version 5 also: bw samples;iops stats(5).
+const (
+	terseHeaderFields     = 5
+	terseDirectionFields3 = 41
+	terseDirectionFields5 = 47
+	terseCPUFields        = 5
+	terseDepthFields      = 7
+	terseLatencyFields    = 22
+	terseDiskFields       = 9
+	tersePercentiles      = 20
+)
+
+// terseDepths - buckets of io depths distribution in terse output
+var terseDepths = []string{"1", "2", "4", "8", "16", "32", "64"}
+
+// terseLine - fields of one line of terse output
+type terseLine struct {
+	fields []string
+	pos    int
+}
+
+// next - next field of line
+func (t *terseLine) next() string {
+	if t.pos >= len(t.fields) {
+		return ""
+	}
+	t.pos++
+	return strings.TrimSpace(t.fields[t.pos-1])
+}
+
+func (t *terseLine) int64() int64 {
+	value, _ := strconv.ParseInt(t.next(), 10, 64)
+	return value
+}
+
+func (t *terseLine) float() float64 {
+	value, _ := strconv.ParseFloat(strings.TrimSuffix(t.next(), "%"), 64)
+	return value
+}
+
+// latency - min;max;mean;dev in usec
+func (t *terseLine) latency(latNS *LatNS) {
+	latNS.Min = t.int64() * 1000
+	latNS.Max = t.int64() * 1000
+	latNS.Mean = t.float() * 1000
+	latNS.Stddev = t.float() * 1000
+}
+
+// percentiles - fields like "99.000000%=1234" with value in usec, unused fields are "0%=0"
+func (t *terseLine) percentiles(latNS *LatNS) {
+	for i := 0; i < tersePercentiles; i++ {
+		pair := strings.SplitN(t.next(), "%=", 2)
+		if len(pair) != 2 {
+			continue
+		}
+		percentile, err := strconv.ParseFloat(pair[0], 64)
+		if err != nil || percentile == 0 {
+			continue
+		}
+		value, err := strconv.ParseInt(pair[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		if latNS.Percentile == nil {
+			latNS.Percentile = make(map[string]int64)
+		}
+		latNS.Percentile[fmt.Sprintf("%f", percentile)] = value * 1000
+	}
+}
+
+// direction - results of one direction
+func (t *terseLine) direction(op *OperationRW, version int) {
+	op.IoKbytes = int(t.int64())
+	op.IoBytes = int64(op.IoKbytes) * 1024
+	op.Bw = int(t.int64())
+	op.Iops = t.float()
+	op.Runtime = int(t.int64())
+	op.TotalIos = int(math.Round(op.Iops * float64(op.Runtime) / 1000))
+	t.latency(&op.SlatNS)
+	t.latency(&op.ClatNS)
+	t.percentiles(&op.ClatNS)
+	t.latency(&op.LatNS)
+	op.BwMin = int(t.int64())
+	op.BwMax = int(t.int64())
+	op.BwAgg = t.float()
+	op.BwMean = t.float()
+	op.BwDev = t.float()
+	if version == 5 {
+		op.BwSamples = int(t.int64())
+		op.IopsMin = int(t.int64())
+		op.IopsMax = int(t.int64())
+		op.IopsMean = t.float()
+		op.IopsStddev = t.float()
+		op.IopsSamples = int(t.int64())
+	}
+}
+
+// IsTerse returns true if line looks like line of fio terse output
+func IsTerse(line []byte) bool {
+	fields := bytes.Split(bytes.TrimSpace(line), []byte(";"))
+	if len(fields) < terseHeaderFields+3*terseDirectionFields3 {
+		return false
+	}
+	version := string(fields[0])
+	return (version == "3" || version == "5") && bytes.HasPrefix(fields[1], []byte("fio-"))
+}
+
+// terseRW - fio rw pattern of job from terse output. Terse output has no job options,
+// so rw is taken from the job name if it starts with rw pattern (Ex. "randread-4k-32"),
+// otherwise it is defined by the directions with IO
+func terseRW(job *Jobs) string {
+	var active []Direction
+	for d := Direction(0); d < DIR_MAX; d++ {
+		if job.Operation(d).IoKbytes > 0 {
+			active = append(active, d)
+		}
+	}
+
+	name := strings.FieldsFunc(job.TestName, func(r rune) bool {
+		return r == '-' || r == '_' || r == ' ' || r == '.'
+	})
+	if len(name) != 0 {
+		nameDirections := JobDirections(name[0])
+		known := map[string]bool{"read": true, "write": true, "trim": true, "randread": true,
+			"randwrite": true, "randtrim": true, "rw": true, "readwrite": true, "randrw": true,
+			"trimwrite": true, "randtrimwrite": true}
+		if known[name[0]] && fmt.Sprint(nameDirections) == fmt.Sprint(active) {
+			return name[0]
+		}
+	}
+
+	switch fmt.Sprint(active) {
+	case fmt.Sprint([]Direction{DIR_READ, DIR_WRITE}):
+		return "rw"
+	case fmt.Sprint([]Direction{DIR_WRITE, DIR_TRIM}):
+		return "trimwrite"
+	case fmt.Sprint([]Direction{DIR_TRIM}):
+		return "trim"
+	case fmt.Sprint([]Direction{DIR_READ}):
+		return "read"
+	}
+	return "write"
+}
+
+// terseBlockSize - block size calculated from bandwidth and IOPS of the first direction with IO
+func terseBlockSize(job *Jobs) string {
+	for _, d := range JobDirections(job.TestOption.RW) {
+		op := job.Operation(d)
+		if op.Iops > 0 && op.Bw > 0 {
+			// round to sector size
+			bs := math.Round(float64(op.Bw)*1024/op.Iops/512) * 512
+			return FormatBlockSize(int64(bs))
+		}
+	}
+	return ""
+}
+
+// parseTerseLine - parse one job from terse output
+func parseTerseLine(line string, fio *FioJSON) error {
+	t := terseLine{fields: strings.Split(line, ";")}
+	version, _ := strconv.Atoi(t.next())
+	directionFields := terseDirectionFields3
+	if version == 5 {
+		directionFields = terseDirectionFields5
+	} else if version != 3 {
+		return fmt.Errorf("unsupported version of terse output: %d", version)
+	}
+	minFields := terseHeaderFields + 3*directionFields + terseCPUFields + terseDepthFields + terseLatencyFields
+	if len(t.fields) < minFields {
+		return fmt.Errorf("not enough fields in terse output: %d, expected at least %d", len(t.fields), minFields)
+	}
+
+	var job Jobs
+	fio.FioVersion = t.next()
+	job.TestName = t.next()
+	job.GroupID = int(t.int64())
+	job.Error = int(t.int64())
+	for d := Direction(0); d < DIR_MAX; d++ {
+		t.direction(job.Operation(d), version)
+	}
+	job.UsrCPU = t.float()
+	job.SysCPU = t.float()
+	job.Ctx = int(t.int64())
+	job.Majf = int(t.int64())
+	job.Minf = int(t.int64())
+
+	// the most used bucket of io depths distribution
+	var maxShare float64
+	for _, depth := range terseDepths {
+		if share := t.float(); share > maxShare {
+			maxShare = share
+			job.TestOption.IODepth = depth
+		}
+	}
+	t.pos += terseLatencyFields
+
+	job.TestOption.RW = terseRW(&job)
+	job.TestOption.BS = terseBlockSize(&job)
+	job.TestOption.NumJobs = "1"
+
+	for len(t.fields)-t.pos >= terseDiskFields && len(fio.Jobs) == 0 {
+		fio.DiskUtil = append(fio.DiskUtil, DiskUtil{
+			Name:        t.next(),
+			ReadIos:     int(t.int64()),
+			WriteIos:    int(t.int64()),
+			ReadMerges:  int(t.int64()),
+			WriteMerges: int(t.int64()),
+			ReadTicks:   t.int64(),
+			WriteTicks:  t.int64(),
+			InQueue:     t.int64(),
+			Util:        t.float(),
+		})
+	}
+
+	fio.Jobs = append(fio.Jobs, job)
+	return nil
+}
+
+// ParseTerse parses fio terse output (versions 3 and 5) into the same model as FIO JSON.
+// Each line of output is one job.
+func ParseTerse(in []byte) (FioJSON, error) {
+	var fio FioJSON
+	scanner := bufio.NewScanner(bytes.NewReader(in))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if !IsTerse([]byte(line)) {
+			continue
+		}
+		if err := parseTerseLine(line, &fio); err != nil {
+			return FioJSON{}, fmt.Errorf("could not parse line %d of terse output: %w", lineNumber, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return FioJSON{}, fmt.Errorf("could not read terse output: %w", err)
+	}
+	if len(fio.Jobs) == 0 {
+		return FioJSON{}, fmt.Errorf("results of jobs not found in terse output")
+	}
+	return fio, nil
+}
@@ -0,0 +1,150 @@
+package bsdata
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseTerse(t *testing.T) {
+	var percentiles = map[string]int64{"1.000000": 176000, "50.000000": 355000, "99.000000": 750000, "99.990000": 2540000}
+	var tests = []struct {
+		file    string
+		version string
+		disk    []DiskUtil
+		jobs    []wantJob
+	}{
+		{
+			// version 3 (fio 2.x): 41 fields per direction without IOPS stats,
+			// rw isn't in job names, it is defined by directions with IO
+			file:    "terse-v3.txt",
+			version: "fio-2.2.10",
+			disk:    []DiskUtil{{Name: "nvme0n1", ReadIos: 3672201, WriteIos: 1573740, ReadTicks: 1339810, WriteTicks: 570201, InQueue: 1910011, Util: 99.93}},
+			jobs: []wantJob{
+				{
+					name:    "seqread",
+					options: JobOptions{RW: "read", BS: "4k", IODepth: "32", NumJobs: "1"},
+					usrCPU:  12.35, ctx: 2209714,
+					ops: map[Direction]wantOperation{
+						DIR_READ: {
+							iops: 6553.5, bw: 26214, ioBytes: 1572864 * 1024, runtime: 60001,
+							slat:        [3]float64{2000, 262000, 5220},
+							clat:        [3]float64{64000, 82893000, 1723030},
+							lat:         [3]float64{72000, 82897000, 1728440},
+							percentiles: percentiles,
+							bwMin:       20408, bwMax: 31560, bwMean: 26229.60,
+							totalIos: 393217,
+						},
+						DIR_WRITE: {},
+						DIR_TRIM:  {},
+					},
+				},
+				{
+					name:    "mixed",
+					options: JobOptions{RW: "rw", BS: "4k", IODepth: "16", NumJobs: "1"},
+					usrCPU:  12.35, ctx: 2209714,
+					ops: map[Direction]wantOperation{
+						DIR_WRITE: {
+							iops: 6553.5, bw: 26214, ioBytes: 1572864 * 1024, runtime: 60001,
+							slat:        [3]float64{2000, 262000, 5220},
+							clat:        [3]float64{64000, 82893000, 1723030},
+							lat:         [3]float64{72000, 82897000, 1728440},
+							percentiles: percentiles,
+							bwMin:       20408, bwMax: 31560, bwMean: 26229.60,
+							totalIos: 393217,
+						},
+						DIR_TRIM: {},
+					},
+				},
+			},
+		},
+		{
+			// version 5 (fio 3.x): 47 fields per direction with IOPS stats,
+			// rw is taken from job name if it matches directions with IO
+			file:    "terse-v5.txt",
+			version: "fio-3.28",
+			disk:    []DiskUtil{{Name: "nvme0n1", ReadIos: 3672201, WriteIos: 1573740, ReadTicks: 1339810, WriteTicks: 570201, InQueue: 1910011, Util: 99.93}},
+			jobs: []wantJob{
+				{
+					name:    "randrw-4k-32",
+					options: JobOptions{RW: "randrw", BS: "4k", IODepth: "32", NumJobs: "1"},
+					usrCPU:  12.35, ctx: 2209714,
+					ops: map[Direction]wantOperation{
+						DIR_READ: {
+							iops: 61200, bw: 244800, ioBytes: 14688000 * 1024, runtime: 60001,
+							slat:        [3]float64{1000, 95000, 2845},
+							clat:        [3]float64{48000, 6812000, 367520},
+							lat:         [3]float64{51000, 6815000, 370420},
+							percentiles: percentiles,
+							bwMin:       228128, bwMax: 256672, bwMean: 244922.40,
+							iopsMin: 57032, iopsMax: 64168, totalIos: 3672061,
+						},
+						DIR_WRITE: {
+							iops: 26230.5, bw: 104922, ioBytes: 6295552 * 1024, runtime: 60001,
+							slat:        [3]float64{1000, 88000, 3120},
+							clat:        [3]float64{52000, 7020000, 370110},
+							lat:         [3]float64{55000, 7024000, 373290},
+							percentiles: map[string]int64{"1.000000": 178000, "99.000000": 758000, "99.900000": 1254000},
+							bwMin:       97416, bwMax: 110136, bwMean: 104903.33,
+							iopsMin: 24354, iopsMax: 27534, totalIos: 1573856,
+						},
+						DIR_TRIM: {},
+					},
+				},
+				{
+					name:    "discard-64k",
+					options: JobOptions{RW: "trim", BS: "64k", IODepth: "8", NumJobs: "1"},
+					usrCPU:  12.35, ctx: 2209714,
+					ops: map[Direction]wantOperation{
+						DIR_READ:  {},
+						DIR_WRITE: {},
+						DIR_TRIM: {
+							iops: 3412.5, bw: 218400, ioBytes: 13104000 * 1024, runtime: 60002,
+							clat:        [3]float64{310000, 18452000, 2337400},
+							lat:         [3]float64{312000, 18455000, 2340050},
+							percentiles: map[string]int64{"50.000000": 2410000, "99.000000": 3260000, "99.990000": 6130000},
+							bwMin:       196608, bwMax: 229376, bwMean: 218450.13,
+							iopsMin: 3072, iopsMax: 3584, totalIos: 204757,
+						},
+					},
+				},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.file, func(t *testing.T) {
+			in, err := os.ReadFile(filepath.Join("testdata", test.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			fio, err := ParseTerse(in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fio.FioVersion != test.version {
+				t.Errorf("version %q, expected %q", fio.FioVersion, test.version)
+			}
+			if len(fio.DiskUtil) != len(test.disk) || fio.DiskUtil[0] != test.disk[0] {
+				t.Errorf("disk stats %+v, expected %+v", fio.DiskUtil, test.disk)
+			}
+			checkJobs(t, fio.Jobs, test.jobs)
+		})
+	}
+}
+
+func TestParseTerseErrors(t *testing.T) {
+	in, err := os.ReadFile(filepath.Join("testdata", "terse-v5.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// line of version 5 with fields of directions for version 3 only
+	fields := strings.Split(strings.SplitN(string(in), "\n", 2)[0], ";")
+	if _, err := ParseTerse([]byte(strings.Join(fields[:170], ";"))); err == nil {
+		t.Errorf("expected error for truncated line of terse output")
+	}
+	if _, err := ParseTerse([]byte("fio-3.28 is not terse output")); err == nil {
+		t.Errorf("expected error for output without jobs")
+	}
+}
@@ -6,93 +6,355 @@ import (
 	"io"
 	"math"
 	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
 	bs "github.com/vk-en/fioplot-bs/pkg/bsdata"
+	"github.com/vk-en/fioplot-bs/pkg/units"
+)
+
+// Names of columns with results for one direction.
+// In CSV table each name has prefix with direction and base unit of values of the column
+// (Ex. "Read Bandwidth (B/s)"), values are not rounded, units of reports are applied when they are drawn
+const (
+	ColPerformance = "Bandwidth"
+	ColBwMin       = "BW min"
+	ColBwMax       = "BW max"
+	ColIopsMin     = "IOPS min"
+	ColIopsMax     = "IOPS max"
+	ColLatMin      = "Latency Min"
+	ColLatMax      = "Latency Max"
+	ColLatStd      = "Latency stddev"
 )
 
-// toFixed is a helper function for rounding float64
-func toFixed(x float64, n int) float64 {
-	var l = math.Pow(10, float64(n))
-	var mbs = math.Round(x*l) / l
-	return mbs * 1.049 // formula from google
+// NotAvailable - value in CSV table for results which are missing in fio output
+const NotAvailable = "n/a"
+
+// LatencyPercentiles - percentiles of latencies which are reported in CSV table
+type LatencyPercentiles struct {
+	Latencies   []string  // types of latency from fio results: clat, lat, slat
+	Percentiles []float64 // Ex. 50, 99, 99.9
+}
+
+// DefaultPercentiles - p99 of completion latency
+var DefaultPercentiles = LatencyPercentiles{
+	Latencies:   []string{"clat"},
+	Percentiles: []float64{99},
+}
+
+// latencyPrefixes - prefixes for names of latencies in columns
+var latencyPrefixes = map[string]string{
+	"clat": "cLatency",
+	"slat": "sLatency",
+	"lat":  "Latency",
+}
+
+// FormatPercentile returns percentile without trailing zeros (Ex. 99.900000 -> "99.9")
+func FormatPercentile(percentile float64) string {
+	return strconv.FormatFloat(percentile, 'f', -1, 64)
+}
+
+// PercentileName returns name of percentile of latency (Ex. "cLatency p99.9")
+func PercentileName(latency string, percentile float64) string {
+	return fmt.Sprintf("%s p%s", latencyPrefixes[latency], FormatPercentile(percentile))
+}
+
+// ParseLatencyPercentiles parses comma separated lists of latencies (Ex. "clat,lat")
+// and percentiles (Ex. "50,99,99.9")
+func ParseLatencyPercentiles(latencies, percentiles string) (LatencyPercentiles, error) {
+	var result LatencyPercentiles
+	for _, latency := range strings.Split(latencies, ",") {
+		latency = strings.TrimSpace(latency)
+		if _, ok := latencyPrefixes[latency]; !ok {
+			return result, fmt.Errorf("unknown type of latency [%s], expected: clat, lat or slat", latency)
+		}
+		result.Latencies = append(result.Latencies, latency)
+	}
+	for _, percentile := range strings.Split(percentiles, ",") {
+		value, err := strconv.ParseFloat(strings.TrimSpace(percentile), 64)
+		if err != nil || value <= 0 || value > 100 {
+			return result, fmt.Errorf("incorrect percentile [%s], expected number in range (0, 100]", percentile)
+		}
+		result.Percentiles = append(result.Percentiles, value)
+	}
+	return result, nil
+}
+
+// Columns returns names of all percentile columns for one direction
+func (lp LatencyPercentiles) Columns() []string {
+	var columns []string
+	for _, latency := range lp.Latencies {
+		for _, percentile := range lp.Percentiles {
+			columns = append(columns, PercentileName(latency, percentile))
+		}
+	}
+	return columns
+}
+
+// Names of columns with common information about job
+const (
+	ColJobName  = "Job Name"
+	ColGroupID  = "Group ID"
+	ColPattern  = "Pattern"
+	ColBs       = "Block Size"
+	ColDepth    = "IO Depth"
+	ColJobs     = "Jobs"
+	ColIOEngine = "IO Engine"
+)
+
+// directionColumns - columns which are written for each direction with quantity of their values
+var directionColumns = []struct {
+	name     string
+	quantity units.Quantity
+}{
+	{ColPerformance, units.Bandwidth},
+	{ColBwMin, units.Bandwidth},
+	{ColBwMax, units.Bandwidth},
+	{ColIopsMin, units.Count},
+	{ColIopsMax, units.Count},
+	{ColLatMin, units.Latency},
+	{ColLatMax, units.Latency},
+	{ColLatStd, units.Latency},
+}
+
+// ColumnName returns name of column with results for direction
+func ColumnName(d bs.Direction, column string) string {
+	name := d.String()
+	return fmt.Sprintf("%s%s %s", strings.ToUpper(name[:1]), name[1:], column)
+}
+
+// TotalColumnName returns name of column with combined results of all directions of job
+func TotalColumnName(column string) string {
+	return "Total " + column
+}
+
+// ParseHeader returns name of column without unit and unit of values from header of CSV table
+// (Ex. "Read Latency Min (ns)" -> "Read Latency Min", ns), columns without unit have Count unit
+func ParseHeader(header string) (string, units.Unit) {
+	if index := strings.LastIndex(header, " ("); index >= 0 && strings.HasSuffix(header, ")") {
+		if unit, _, ok := units.Lookup(header[index+2 : len(header)-1]); ok {
+			return header[:index], unit
+		}
+	}
+	return header, units.Unit{Scale: 1}
+}
+
+// directionRow - results of one direction in base units (nanoseconds, bytes per second) in the order
+// of directionColumns and percentile columns, missing percentiles are NaN.
+// Returns also names of percentiles which are missing in results of the active direction.
+func directionRow(op *bs.OperationRW, percentiles LatencyPercentiles, active bool) ([]float64, []string) {
+	var missing []string
+	var row = []float64{
+		float64(op.Bw) * units.KiB,
+		float64(op.BwMin) * units.KiB,
+		float64(op.BwMax) * units.KiB,
+		float64(op.IopsMin),
+		float64(op.IopsMax),
+		float64(op.LatNS.Min),
+		float64(op.LatNS.Max),
+		op.LatNS.Stddev,
+	}
+	for _, latency := range percentiles.Latencies {
+		latNS, _ := op.Latency(latency)
+		for _, percentile := range percentiles.Percentiles {
+			value, ok := latNS.PercentileValue(percentile)
+			if !ok {
+				if active {
+					missing = append(missing, fmt.Sprintf("%s p%s", latency, FormatPercentile(percentile)))
+				}
+				row = append(row, math.NaN())
+				continue
+			}
+			row = append(row, float64(value))
+		}
+	}
+	return row, missing
+}
+
+// totalRow - combined results of directions of job in the same order as directionRow.
+// Bandwidth is summed, minimum and maximum of latency are taken over directions and standard deviation
+// of latency is pooled by counts of IOs. Percentiles are calculated from merged latency bins of json+ output
+// and are NaN without them. Minimum and maximum of bandwidth and IOPS are sampled for each direction
+// separately, so they can not be combined and are NaN for jobs with several directions
+func totalRow(ops []*bs.OperationRW, percentiles LatencyPercentiles) []float64 {
+	if len(ops) == 1 {
+		row, _ := directionRow(ops[0], percentiles, false)
+		return row
+	}
+	var bw, ios, mean float64
+	for _, op := range ops {
+		bw += float64(op.Bw) * units.KiB
+		ios += float64(op.TotalIos)
+		mean += float64(op.TotalIos) * op.LatNS.Mean
+	}
+	var latMin, latMax, latStd = math.NaN(), math.NaN(), math.NaN()
+	if ios != 0 {
+		mean /= ios
+		latMin, latMax = math.Inf(1), math.Inf(-1)
+		var squares float64
+		for _, op := range ops {
+			if op.TotalIos == 0 {
+				continue
+			}
+			n := float64(op.TotalIos)
+			latMin = math.Min(latMin, float64(op.LatNS.Min))
+			latMax = math.Max(latMax, float64(op.LatNS.Max))
+			squares += (n-1)*op.LatNS.Stddev*op.LatNS.Stddev + n*(op.LatNS.Mean-mean)*(op.LatNS.Mean-mean)
+		}
+		if ios > 1 {
+			latStd = math.Sqrt(squares / (ios - 1))
+		}
+	}
+	var row = []float64{bw, math.NaN(), math.NaN(), math.NaN(), math.NaN(), latMin, latMax, latStd}
+
+	for _, latency := range percentiles.Latencies {
+		merged := make(map[int64]int64)
+		for _, op := range ops {
+			latNS, _ := op.Latency(latency)
+			bins := latNS.LatencyBins()
+			if len(bins) == 0 && op.TotalIos != 0 {
+				// results without json+ bins
+				merged = nil
+				break
+			}
+			for value, count := range bins {
+				merged[value] += count
+			}
+		}
+		for _, percentile := range percentiles.Percentiles {
+			value, ok := bs.BinsPercentile(merged, percentile)
+			if !ok {
+				row = append(row, math.NaN())
+				continue
+			}
+			row = append(row, float64(value))
+		}
+	}
+	return row
 }
 
-// mbps is a helper function for converting bytes to MB/s
-func mbps(x int) float64 {
-	return toFixed(float64(x)/1024, 2)
+// formatValue - value in base units for CSV table with full precision, missing value is "n/a"
+func formatValue(value float64) string {
+	if math.IsNaN(value) {
+		return NotAvailable
+	}
+	return strconv.FormatFloat(value, 'f', -1, 64)
 }
 
-// formatCSV formats CSV input
-func formatCSV(in bs.FioJSON, to io.Writer) error {
-	var header = []string{
-		"Job Name", "Group ID", "Pattern", "Block Size", "IO Depth", "Jobs",
-		"MB/s", "BWMim (MB/s)", "BWMax (MB/s)", "IOPS min", "IOPS max",
-		"Latency Min (ms)", "Latency Max (ms)", "Latency stddev (ms)",
-		"cLatency p99 (ms)",
+// formatCSV formats CSV input. Returns names of percentiles which are missing in fio results
+func formatCSV(in bs.FioJSON, to io.Writer, opts Options) ([]string, error) {
+	var percentiles = opts.Percentiles
+	var missing []string
+	var uniqMissing = make(map[string]bool)
+
+	// rows are sorted in the same order as patterns in reports
+	var jobs = make([]int, len(in.Jobs))
+	var jobOptions = make([]bs.JobOptions, len(in.Jobs))
+	for i := range in.Jobs {
+		jobs[i] = i
+		jobOptions[i] = in.Jobs[i].EffectiveOptions(&in.GlobalOptions)
+	}
+	sort.SliceStable(jobs, func(i, j int) bool {
+		return bs.ComparePatterns(jobOptions[jobs[i]].PatternKey(), jobOptions[jobs[j]].PatternKey(), opts.SortOrder) < 0
+	})
+
+	var names []string
+	var quantities []units.Quantity
+	for _, column := range directionColumns {
+		names = append(names, column.name)
+		quantities = append(quantities, column.quantity)
+	}
+	for _, column := range percentiles.Columns() {
+		names = append(names, column)
+		quantities = append(quantities, units.Latency)
+	}
+	var values = make([][]float64, len(jobs))
+	for row, index := range jobs {
+		var active = make(map[bs.Direction]bool)
+		for _, d := range bs.JobDirections(jobOptions[index].RW) {
+			active[d] = true
+		}
+		var ops []*bs.OperationRW
+		for d := bs.Direction(0); d < bs.DIR_MAX; d++ {
+			if active[d] {
+				ops = append(ops, in.Jobs[index].Operation(d))
+			}
+			dRow, dMissing := directionRow(in.Jobs[index].Operation(d), percentiles, active[d])
+			values[row] = append(values[row], dRow...)
+			for _, name := range dMissing {
+				name = fmt.Sprintf("%s %s", d, name)
+				if !uniqMissing[name] {
+					uniqMissing[name] = true
+					missing = append(missing, name)
+				}
+			}
+		}
+		values[row] = append(values[row], totalRow(ops, percentiles)...)
+	}
+	var header = []string{ColJobName, ColGroupID, ColPattern, ColBs, ColDepth, ColJobs, ColIOEngine}
+	for d := bs.Direction(0); d < bs.DIR_MAX; d++ {
+		for column, name := range names {
+			header = append(header, units.BaseUnit(quantities[column]).Label(ColumnName(d, name)))
+		}
+	}
+	for column, name := range names {
+		header = append(header, units.BaseUnit(quantities[column]).Label(TotalColumnName(name)))
 	}
 
 	var w = csv.NewWriter(to)
 	if err := w.Write(header); err != nil {
-		return err
-	}
-
-	for _, v := range in.Jobs {
-		var bw = v.Write.Bw
-		var bwMin = v.Write.BwMin
-		var bwMax = v.Write.BwMax
-		var iopsMin = v.Write.IopsMin
-		var iopsMax = v.Write.IopsMax
-		var latNsMin = float64(v.Write.LatNS.Min) / 1000000
-		var latNsMax = float64(v.Write.LatNS.Max) / 1000000
-		var latNsStdDev = v.Write.LatNS.Stddev / 1000000
-		var cLatNsPercent = float64(v.Write.ClatNS.Percentile["99.000000"]) / 1000000
-
-		if v.TestOption.RW == "read" || v.TestOption.RW == "randread" {
-			bw = v.Read.Bw
-			bwMin = v.Read.BwMin
-			bwMax = v.Read.BwMax
-			iopsMin = v.Read.IopsMin
-			iopsMax = v.Read.IopsMax
-			latNsMin = float64(v.Read.LatNS.Min) / 1000000
-			latNsMax = float64(v.Read.LatNS.Max) / 1000000
-			latNsStdDev = v.Read.LatNS.Stddev / 1000000
-			cLatNsPercent = float64(v.Read.ClatNS.Percentile["99.000000"]) / 1000000
-		}
-		var row = []string{
+		return nil, err
+	}
+
+	for row, index := range jobs {
+		var v = &in.Jobs[index]
+		var options = jobOptions[index]
+		var record = []string{
 			v.TestName,
 			fmt.Sprintf("%v", v.GroupID),
-			v.TestOption.RW,
-			v.TestOption.BS,
-			v.TestOption.IODepth,
-			v.TestOption.NumJobs,
-			fmt.Sprintf("%.2f", mbps(bw)),
-			fmt.Sprintf("%.2f", mbps(bwMin)),
-			fmt.Sprintf("%.2f", mbps(bwMax)),
-			fmt.Sprintf("%d", iopsMin),
-			fmt.Sprintf("%d", iopsMax),
-			fmt.Sprintf("%.2f", latNsMin),
-			fmt.Sprintf("%.2f", latNsMax),
-			fmt.Sprintf("%.2f", latNsStdDev),
-			fmt.Sprintf("%.2f", cLatNsPercent),
+			options.RW,
+			options.BS,
+			options.IODepth,
+			options.NumJobs,
+			options.Ioengine,
 		}
-		if err := w.Write(row); err != nil {
-			return err
+		for _, value := range values[row] {
+			record = append(record, formatValue(value))
+		}
+		if err := w.Write(record); err != nil {
+			return nil, err
 		}
 	}
 
 	w.Flush()
-	return nil
+	return missing, w.Error()
 }
 
-// ConvertJSONtoCSV converts JSON input to CSV file
-func ConvertJSONtoCSV(fioJSON bs.FioJSON, outputPath string) error {
+// Options - options for CSV tables
+type Options struct {
+	Percentiles LatencyPercentiles // DefaultPercentiles if empty
+	SortOrder   []string           // order of keys for sorting of jobs, bs.DefaultSortOrder if empty
+}
+
+// ConvertJSONtoCSV converts JSON input to CSV file.
+// Percentiles which are missing in fio results are written as "n/a"
+func ConvertJSONtoCSV(fioJSON bs.FioJSON, outputPath string, opts Options) error {
 	fd, err := os.Create(outputPath)
 	if err != nil {
 		return fmt.Errorf("could not create CSV file [%s]: %w", outputPath, err)
 	}
 	defer fd.Close()
 
-	if err := formatCSV(fioJSON, fd); err != nil {
+	missing, err := formatCSV(fioJSON, fd, opts)
+	if err != nil {
 		return fmt.Errorf("could not format CSV: %w", err)
 	}
+	if len(missing) != 0 {
+		fmt.Printf("percentiles not found in fio results for [%s], they are reported as %s: %s\n",
+			filepath.Base(outputPath), NotAvailable, strings.Join(missing, ", "))
+		fmt.Println("(check fio options: percentile_list, lat_percentiles, slat_percentiles)")
+	}
 	return nil
 }
@@ -0,0 +1,58 @@
+package getdata
+
+import (
+	"fmt"
+	"math"
+)
+
+// Delta - change of value relative to baseline in percent, NaN if it can't be calculated
+func Delta(value, baseline float64) float64 {
+	if math.IsNaN(value) || math.IsNaN(baseline) || baseline == 0 {
+		return math.NaN()
+	}
+	return (value - baseline) / baseline * 100
+}
+
+// IsRegression - true if delta in percent is a change for the worse for metric
+func (m Metric) IsRegression(delta float64) bool {
+	if math.IsNaN(delta) {
+		return false
+	}
+	if m.LowerIsBetter {
+		return delta > 0
+	}
+	return delta < 0
+}
+
+// LegendIndex - index of legend in pattern results, -1 if it is not found
+func (r *AllPatternResults) LegendIndex(legend string) int {
+	for index, name := range r.Legends {
+		if name == legend {
+			return index
+		}
+	}
+	return -1
+}
+
+// Value - value of test in pattern results, NaN if there is no test
+func (r *AllPatternResults) Value(legend string) float64 {
+	if index := r.LegendIndex(legend); index >= 0 {
+		return r.Values[index]
+	}
+	return math.NaN()
+}
+
+// CompareWithBaseline - set percent deltas of all tests to baseline test for each pattern
+func (t PatternsTable) CompareWithBaseline(baseline string) error {
+	for _, pattern := range t {
+		index := pattern.LegendIndex(baseline)
+		if index < 0 {
+			return fmt.Errorf("baseline test [%s] not found in results of pattern [%s]", baseline, pattern.PatternName)
+		}
+		pattern.Deltas = make([]float64, len(pattern.Values))
+		for i, value := range pattern.Values {
+			pattern.Deltas[i] = Delta(value, pattern.Values[index])
+		}
+	}
+	return nil
+}
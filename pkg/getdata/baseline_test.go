@@ -0,0 +1,44 @@
+package getdata
+
+import (
+	"testing"
+)
+
+// TestBaselineWithDots - baseline and candidates are found by names of tests with dots
+func TestBaselineWithDots(t *testing.T) {
+	dir := t.TempDir()
+	files := []string{
+		writeTestCSV(t, dir, "TestA.v1", map[string]int{"a": 1000}),
+		writeTestCSV(t, dir, "TestA.v2", map[string]int{"a": 1100}),
+	}
+	var results AllResults
+	for _, file := range files {
+		if err := results.ParsingCSVfile(file, Options{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	patterns, err := GetPatterns(results, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var table PatternsTable
+	table.GetPatternTable(patterns, results, Metrics(Options{})[0])
+	if err := table.CompareWithBaseline("TestA.v1"); err != nil {
+		t.Fatal(err)
+	}
+	for i, legend := range table[0].Legends {
+		want := map[string]float64{"TestA.v1": 0, "TestA.v2": 10}[legend]
+		if table[0].Deltas[i] != want {
+			t.Errorf("delta of %s is %v, expected %v", legend, table[0].Deltas[i], want)
+		}
+	}
+
+	checks, err := CheckRegressions(files, Options{Baseline: "TestA.v1"}, nil,
+		Thresholds{Metrics: map[string]float64{"Performance": 5}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(checks) != 1 || checks[0].Test != "TestA.v2" || checks[0].Status != CheckPass {
+		t.Errorf("expected passed check of TestA.v2, got %+v", checks)
+	}
+}
@@ -0,0 +1,251 @@
+package getdata
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/vk-en/fioplot-bs/pkg/units"
+)
+
+// Statuses of checks
+const (
+	CheckPass = "pass"
+	CheckFail = "fail"
+	CheckNA   = "n/a" // value is missing in results of baseline or candidate and fail_on_missing is false
+)
+
+// Thresholds - allowed regressions of metrics in percent, Ex.
+// {"metrics": {"Performance": 5, "cLatency_p99": 10}, "default": 20}
+// performance may not drop more than 5%, p99 latency may not rise more than 10%
+// and other metrics may not become worse more than 20%
+type Thresholds struct {
+	Metrics       map[string]float64 `json:"metrics"`         // name of metric (name of xlsx sheet) -> allowed regression (%)
+	Default       *float64           `json:"default"`         // allowed regression for other metrics, they are not checked if it is empty
+	FailOnMissing *bool              `json:"fail_on_missing"` // check fails if value is missing, true if it is empty
+}
+
+// CheckResult - result of comparison of candidate with baseline for one pattern and metric
+type CheckResult struct {
+	Test         string // name of candidate test
+	BaselineTest string
+	Pattern      string
+	Metric       Metric
+	Baseline     float64
+	Candidate    float64
+	Unit         units.Unit // unit of values of baseline and candidate
+	Delta        float64    // percent delta of candidate to baseline
+	Threshold    float64    // allowed regression (%)
+	Status       string
+}
+
+// Failed - true if candidate has regression more than threshold or value is missing
+func (r CheckResult) Failed() bool {
+	return r.Status == CheckFail
+}
+
+// Missing - true if value of baseline or candidate is missing in results
+func (r CheckResult) Missing() bool {
+	return math.IsNaN(r.Baseline) || math.IsNaN(r.Candidate)
+}
+
+// ReadThresholds - read thresholds from JSON config file
+func ReadThresholds(path string) (Thresholds, error) {
+	var thresholds Thresholds
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return thresholds, fmt.Errorf("could not read file with thresholds: %w", err)
+	}
+	if err := json.Unmarshal(content, &thresholds); err != nil {
+		return thresholds, fmt.Errorf("invalid file with thresholds [%s]: %w", path, err)
+	}
+	for name, threshold := range thresholds.Metrics {
+		if threshold < 0 {
+			return thresholds, fmt.Errorf("threshold for metric [%s] is negative: %g", name, threshold)
+		}
+	}
+	if thresholds.Default != nil && *thresholds.Default < 0 {
+		return thresholds, fmt.Errorf("default threshold is negative: %g", *thresholds.Default)
+	}
+	return thresholds, nil
+}
+
+// failOnMissing - true if check fails when value is missing in results
+func (t Thresholds) failOnMissing() bool {
+	return t.FailOnMissing == nil || *t.FailOnMissing
+}
+
+// threshold - allowed regression for metric
+func (t Thresholds) threshold(metric Metric) (float64, bool) {
+	if threshold, ok := t.Metrics[metric.FileName]; ok {
+		return threshold, true
+	}
+	if t.Default != nil {
+		return *t.Default, true
+	}
+	return 0, false
+}
+
+// checkMetrics - metrics which have thresholds, error for thresholds of unknown metrics
+func (t Thresholds) checkMetrics(opts Options) ([]Metric, error) {
+	var metrics []Metric
+	known := make(map[string]bool)
+	for _, metric := range Metrics(opts) {
+		known[metric.FileName] = true
+		if _, ok := t.threshold(metric); ok {
+			metrics = append(metrics, metric)
+		}
+	}
+
+	var unknown []string
+	for name := range t.Metrics {
+		if !known[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) != 0 {
+		sort.Strings(unknown)
+		var names []string
+		for _, metric := range Metrics(opts) {
+			names = append(names, metric.FileName)
+		}
+		return nil, fmt.Errorf("thresholds for unknown metrics: %s (metrics: %s, percentiles are set by --percentiles)",
+			strings.Join(unknown, ", "), strings.Join(names, ", "))
+	}
+	if len(metrics) == 0 {
+		return nil, fmt.Errorf("there are no thresholds for metrics")
+	}
+	return metrics, nil
+}
+
+// checkPattern - compare candidate with baseline for one pattern.
+// Missing value fails if failOnMissing is true. If baseline is 0, any value of candidate
+// above it is an infinite rise
+func checkPattern(pattern *AllPatternResults, metric Metric, baseline, candidate string, threshold float64, failOnMissing bool) CheckResult {
+	result := CheckResult{
+		Test:         candidate,
+		BaselineTest: baseline,
+		Pattern:      pattern.PatternName,
+		Metric:       metric,
+		Baseline:     pattern.Value(baseline),
+		Candidate:    pattern.Value(candidate),
+		Unit:         pattern.Unit,
+		Threshold:    threshold,
+		Status:       CheckPass,
+	}
+	result.Delta = Delta(result.Candidate, result.Baseline)
+	if result.Baseline == 0 && result.Candidate == 0 {
+		result.Delta = 0
+	} else if result.Baseline == 0 && result.Candidate > 0 {
+		result.Delta = math.Inf(1)
+	}
+	switch {
+	case result.Missing() && failOnMissing:
+		result.Status = CheckFail
+	case result.Missing():
+		result.Status = CheckNA
+	case metric.IsRegression(result.Delta) && math.Abs(result.Delta) > threshold:
+		result.Status = CheckFail
+	}
+	return result
+}
+
+// CheckRegressions - compare candidate tests with baseline test for each common pattern
+// and each metric with threshold. All tests except baseline are checked if candidates are empty
+func CheckRegressions(csvFiles []string, opts Options, candidates []string, thresholds Thresholds) ([]CheckResult, error) {
+	if opts.Baseline == "" {
+		return nil, fmt.Errorf("baseline test is required for check")
+	}
+	metrics, err := thresholds.checkMetrics(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var testResults = make(AllResults, 0)
+	for _, file := range csvFiles {
+		if err := testResults.ParsingCSVfile(file, opts); err != nil {
+			return nil, fmt.Errorf("parsing csv file [%s] failed: %w", file, err)
+		}
+	}
+
+	identicalPatterns, err := GetPatterns(testResults, opts)
+	if err != nil {
+		return nil, fmt.Errorf("could not get patterns for report: %w", err)
+	}
+
+	var results []CheckResult
+	for _, metric := range metrics {
+		threshold, _ := thresholds.threshold(metric)
+		var pTable = make(PatternsTable, 0)
+		pTable.GetPatternTable(identicalPatterns, testResults, metric)
+		if len(pTable) == 0 {
+			continue
+		}
+
+		tests := candidates
+		if len(tests) == 0 {
+			for _, legend := range pTable[0].Legends {
+				if legend != opts.Baseline {
+					tests = append(tests, legend)
+				}
+			}
+		}
+		for _, test := range tests {
+			if pTable[0].LegendIndex(test) < 0 {
+				return nil, fmt.Errorf("candidate test [%s] not found in results", test)
+			}
+			for _, pattern := range pTable {
+				results = append(results, checkPattern(pattern, metric, opts.Baseline, test, threshold, thresholds.failOnMissing()))
+			}
+		}
+	}
+	return results, nil
+}
+
+// FormatCheckValue - value for table of checks, n/a for missing value
+func FormatCheckValue(value float64, format string) string {
+	if math.IsNaN(value) {
+		return CheckNA
+	}
+	return fmt.Sprintf(format, value)
+}
+
+// FormatValue - value of baseline or candidate with unit (Ex. "1.250 ms"), n/a for missing value
+func (r CheckResult) FormatValue(value float64) string {
+	if r.Unit.Name == "" || math.IsNaN(value) {
+		return FormatCheckValue(value, "%.3f")
+	}
+	return FormatCheckValue(value, "%.3f "+r.Unit.Name)
+}
+
+// FormatThreshold - allowed drop of performance (Ex. -5%) or rise of latency (Ex. +10%)
+func (r CheckResult) FormatThreshold() string {
+	if r.Metric.LowerIsBetter {
+		return fmt.Sprintf("+%g%%", r.Threshold)
+	}
+	return fmt.Sprintf("-%g%%", r.Threshold)
+}
+
+// PrintCheckResults - print table of checks, returns count of failed checks
+func PrintCheckResults(w io.Writer, results []CheckResult) int {
+	failed := 0
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "TEST\tPATTERN\tMETRIC\tBASELINE\tCANDIDATE\tDELTA\tTHRESHOLD\tSTATUS")
+	for _, result := range results {
+		if result.Failed() {
+			failed++
+		}
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			result.Test, result.Pattern, result.Metric.FileName,
+			result.FormatValue(result.Baseline), result.FormatValue(result.Candidate),
+			FormatCheckValue(result.Delta, "%+.2f%%"), result.FormatThreshold(), strings.ToUpper(result.Status))
+	}
+	table.Flush()
+	fmt.Fprintf(w, "%d checks, %d failed\n", len(results), failed)
+	return failed
+}
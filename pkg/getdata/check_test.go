@@ -0,0 +1,81 @@
+package getdata
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckPattern(t *testing.T) {
+	var performance, latency Metric
+	for _, metric := range Metrics(Options{}) {
+		switch metric.FileName {
+		case "Performance":
+			performance = metric
+		case "cLatency_p99":
+			latency = metric
+		}
+	}
+	nan := math.NaN()
+	var tests = []struct {
+		name          string
+		metric        Metric
+		baseline      float64
+		candidate     float64
+		failOnMissing bool
+		status        string
+	}{
+		{"drop of performance in threshold", performance, 100, 96, true, CheckPass},
+		{"drop of performance above threshold", performance, 100, 90, true, CheckFail},
+		{"rise of latency in threshold", latency, 100, 104, true, CheckPass},
+		{"rise of latency above threshold", latency, 100, 110, true, CheckFail},
+		{"missing percentile of candidate", latency, 100, nan, true, CheckFail},
+		{"missing percentile of baseline", latency, nan, 100, true, CheckFail},
+		{"missing value is allowed", latency, 100, nan, false, CheckNA},
+		{"latency rises from zero", latency, 0, 3, true, CheckFail},
+		{"performance rises from zero", performance, 0, 3, true, CheckPass},
+		{"zero in both tests", latency, 0, 0, true, CheckPass},
+	}
+	for _, test := range tests {
+		pattern := &AllPatternResults{PatternName: "randread-4k d=32 j=1", Legends: []string{"TestA", "TestB"},
+			Values: []float64{test.baseline, test.candidate}}
+		result := checkPattern(pattern, test.metric, "TestA", "TestB", 5, test.failOnMissing)
+		if result.Status != test.status || result.Failed() != (test.status == CheckFail) {
+			t.Errorf("%s: status %s, delta %v, expected %s", test.name, result.Status, result.Delta, test.status)
+		}
+	}
+
+	// pattern which is missing in candidate with --partial
+	pattern := &AllPatternResults{PatternName: "randread-4k d=32 j=1", Legends: []string{"TestA"}, Values: []float64{100}}
+	if result := checkPattern(pattern, performance, "TestA", "TestB", 5, true); !result.Failed() || !result.Missing() {
+		t.Errorf("missing pattern of candidate: status %s, expected %s", result.Status, CheckFail)
+	}
+}
+
+func TestReadThresholds(t *testing.T) {
+	var tests = []struct {
+		content       string
+		valid         bool
+		failOnMissing bool
+	}{
+		{`{"metrics": {"Performance": 5}, "default": 20}`, true, true},
+		{`{"metrics": {"Performance": 5}, "fail_on_missing": false}`, true, false},
+		{`{"metrics": {"Performance": -5}}`, false, true},
+		{`{"metrics": {"Performance": 5}, "default": -20}`, false, true},
+	}
+	for _, test := range tests {
+		path := filepath.Join(t.TempDir(), "thresholds.json")
+		if err := os.WriteFile(path, []byte(test.content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		thresholds, err := ReadThresholds(path)
+		if (err == nil) != test.valid {
+			t.Errorf("%s: error %v, expected valid=%v", test.content, err, test.valid)
+			continue
+		}
+		if test.valid && thresholds.failOnMissing() != test.failOnMissing {
+			t.Errorf("%s: fail_on_missing is %v, expected %v", test.content, thresholds.failOnMissing(), test.failOnMissing)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package getdata
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+
+	csvt "github.com/vk-en/fioplot-bs/pkg/csvtable"
+)
+
+// CoveragePresent - value of coverage matrix for test which has results of pattern
+const CoveragePresent = "yes"
+
+// Coverage - matrix of patterns and tests which have results of them
+type Coverage struct {
+	Tests      []string
+	Patterns   []string
+	Duplicates []string                   // jobs of tests which are skipped because of other job with the same pattern
+	present    map[string]map[string]bool // pattern -> test -> true
+}
+
+// GetCoverage - coverage of all patterns by tests, repetitions of one test are one column,
+// patterns are sorted by keys of opts.SortOrder
+func GetCoverage(results AllResults, opts Options) Coverage {
+	coverage := Coverage{present: make(map[string]map[string]bool)}
+	known := make(map[string]bool)
+	for _, test := range results {
+		group := test.groupName()
+		if !known[group] {
+			known[group] = true
+			coverage.Tests = append(coverage.Tests, group)
+		}
+		for _, duplicate := range test.Duplicates {
+			coverage.Duplicates = append(coverage.Duplicates, fmt.Sprintf("%s: %s", test.TestName, duplicate))
+		}
+		for _, result := range test.IOTestResults {
+			if _, ok := coverage.present[result.Pattern]; !ok {
+				coverage.present[result.Pattern] = make(map[string]bool)
+				coverage.Patterns = append(coverage.Patterns, result.Pattern)
+			}
+			coverage.present[result.Pattern][group] = true
+		}
+	}
+	SortPatterns(coverage.Patterns, results, opts.SortOrder)
+	return coverage
+}
+
+// Has - true if test has results of pattern
+func (c Coverage) Has(pattern, test string) bool {
+	return c.present[pattern][test]
+}
+
+// Partial - patterns which are not in all tests
+func (c Coverage) Partial() []string {
+	var partial []string
+	for _, pattern := range c.Patterns {
+		if len(c.present[pattern]) != len(c.Tests) {
+			partial = append(partial, pattern)
+		}
+	}
+	return partial
+}
+
+// Records - coverage matrix for CSV table, missing results are "n/a"
+func (c Coverage) Records() [][]string {
+	var records = [][]string{append([]string{"Pattern"}, c.Tests...)}
+	for _, pattern := range c.Patterns {
+		var record = []string{pattern}
+		for _, test := range c.Tests {
+			if c.Has(pattern, test) {
+				record = append(record, CoveragePresent)
+			} else {
+				record = append(record, csvt.NotAvailable)
+			}
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+// CreateCoverageCSV - create CSV table with coverage of patterns by tests,
+// returns coverage to report patterns which are not in all tests
+func CreateCoverageCSV(csvFiles []string, pathToCsv string, opts Options) (Coverage, error) {
+	var testResults = make(AllResults, 0)
+	for _, file := range csvFiles {
+		if err := testResults.ParsingCSVfile(file, opts); err != nil {
+			return Coverage{}, fmt.Errorf("parsing csv file [%s] failed: %w", file, err)
+		}
+	}
+	coverage := GetCoverage(testResults, opts)
+
+	csvFile, err := os.Create(pathToCsv)
+	if err != nil {
+		return coverage, fmt.Errorf("could not create CSV file [%s]: %w", pathToCsv, err)
+	}
+	defer csvFile.Close()
+
+	writer := csv.NewWriter(csvFile)
+	if err := writer.WriteAll(coverage.Records()); err != nil {
+		return coverage, fmt.Errorf("could not write CSV file [%s]: %w", pathToCsv, err)
+	}
+	return coverage, nil
+}
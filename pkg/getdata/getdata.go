@@ -6,28 +6,34 @@ import (
 	"math"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
+
+	bs "github.com/vk-en/fioplot-bs/pkg/bsdata"
+	csvt "github.com/vk-en/fioplot-bs/pkg/csvtable"
+	"github.com/vk-en/fioplot-bs/pkg/units"
 )
 
-// GroupResults - struct for group results
-// Curent format CSV: "Group ID", "Pattern", "Block Size", "IO Depth", "Jobs", "Mb/s"
+// GroupResults - struct for group results of one direction of a job
 type GroupResults struct {
-	JobName		string
+	JobName     string
 	GroupID     string
 	Pattern     string
 	Bs          string
 	Depth       string
 	JobsCount   string
-	Performance string
-	BwMin       string
-	BwMax       string
-	IopsMin     int
-	IopsMax     int
-	LatMin      float64
+	IOEngine    string
+	Direction   string
+	Performance float64 // bandwidth in bytes per second
+	BwMin       float64
+	BwMax       float64
+	IopsMin     float64
+	IopsMax     float64
+	LatMin      float64 // latencies in nanoseconds
 	LatMax      float64
 	LatStd      float64
-	CLatPercent float64
+	Percentiles map[string]float64 // name of percentile column -> value, NaN if it is missing
 }
 
 // TestResult - struct for test results
@@ -44,15 +50,58 @@ type ListAllResults struct {
 	IOTestResults GroupTestRes
 	FileName      string
 	TestName      string
+	Group         string   // name of test in reports, repetitions of one test have the same group
+	Duplicates    []string // jobs which are skipped because the test has other job with the same pattern
 }
 
 // AllPatternResults - struct for all pattern results
 type AllPatternResults struct {
 	PatternName  string
-	Values       []float64
+	Values       []float64 // mean values of tests
+	Stats        []Stats   // statistics over repetitions of tests
+	Deltas       []float64 // percent deltas to baseline test, set by CompareWithBaseline
 	Legends      []string
 	YDiscription string
 	FileName     string
+	Unit         units.Unit // unit of values and statistics, it is chosen for all patterns of table
+}
+
+// MixedMode - how results of jobs with mixed directions (rw, randrw) are reported
+type MixedMode int
+
+const (
+	// MixedSplit - separate pattern for each direction ("randrw-4k d=32 j=1 read")
+	MixedSplit MixedMode = iota
+	// MixedTotal - one pattern with combined results of all directions
+	MixedTotal
+	// MixedBoth - separate patterns for each direction and for combined results
+	MixedBoth
+)
+
+// TotalDirection - name of direction with combined results of mixed jobs
+const TotalDirection = "total"
+
+// Options - options for parsing results and building pattern tables
+type Options struct {
+	Mixed       MixedMode
+	Percentiles csvt.LatencyPercentiles // csvt.DefaultPercentiles if empty
+	Baseline    string                  // name of test which other tests are compared with
+	Partial     bool                    // report patterns which are only in some tests
+	Groups      map[string]string       // test name -> name of test which results are repetitions of
+	SortOrder   []string                // order of keys for sorting of patterns, bs.DefaultSortOrder if empty
+	PatternKey  string                  // template of pattern names which jobs are matched by between tests, see ParsePatternKey
+	Scaling     bool                    // add tables of metrics by iodepth and numjobs to reports
+	Units       units.System            // units of latency and bandwidth, chosen for each table by range of values if not set
+}
+
+// Metric - value from results which is compared between tests
+type Metric struct {
+	FileName      string // name of sheet in xlsx and of bar charts
+	YDiscription  string // name of values without unit, unit is added by table of patterns
+	Quantity      units.Quantity
+	LowerIsBetter bool // true for latencies, false for performance
+	value         func(res *GroupResults) float64
+	units         units.System
 }
 
 // PatternsTable - type for table of patterns from AllPatternResults
@@ -61,17 +110,88 @@ type PatternsTable []*AllPatternResults
 // AllResults - just all reuslts
 type AllResults []*ListAllResults
 
-const (
-	performance uint16 = 1 << iota
-	minIOPS
-	maxIOPS
-	minBW
-	maxBW
-	minLat
-	maxLat
-	stdLat
-	p99Lat
-)
+// latencyPercentiles - percentiles from options or default percentiles
+func (o Options) latencyPercentiles() csvt.LatencyPercentiles {
+	if len(o.Percentiles.Latencies) == 0 || len(o.Percentiles.Percentiles) == 0 {
+		return csvt.DefaultPercentiles
+	}
+	return o.Percentiles
+}
+
+// GroupName - name of test in reports, it is the same for all repetitions of the test
+func (o Options) GroupName(testName string) string {
+	if group, ok := o.Groups[testName]; ok && group != "" {
+		return group
+	}
+	return testName
+}
+
+// Metrics - list of metrics for reports, percentiles are taken from options
+func Metrics(opts Options) []Metric {
+	var metrics = []Metric{
+		{"Performance", "Bandwidth", units.Bandwidth, false,
+			func(res *GroupResults) float64 { return res.Performance }, opts.Units},
+		{"IOPS_min_value", "IOPS min", units.Count, false,
+			func(res *GroupResults) float64 { return res.IopsMin }, opts.Units},
+		{"IOPS_max_value", "IOPS max", units.Count, false,
+			func(res *GroupResults) float64 { return res.IopsMax }, opts.Units},
+		{"BW_min_value", "BW Min", units.Bandwidth, false,
+			func(res *GroupResults) float64 { return res.BwMin }, opts.Units},
+		{"BW_max_value", "BW Max", units.Bandwidth, false,
+			func(res *GroupResults) float64 { return res.BwMax }, opts.Units},
+		{"Latency_min_value", "Latency min", units.Latency, true,
+			func(res *GroupResults) float64 { return res.LatMin }, opts.Units},
+		{"Latency_max_value", "Latency max", units.Latency, true,
+			func(res *GroupResults) float64 { return res.LatMax }, opts.Units},
+		{"Latency_stdev", "Latency stddev", units.Latency, true,
+			func(res *GroupResults) float64 { return res.LatStd }, opts.Units},
+	}
+
+	percentiles := opts.latencyPercentiles()
+	for _, latency := range percentiles.Latencies {
+		for _, percentile := range percentiles.Percentiles {
+			column := csvt.PercentileName(latency, percentile)
+			metrics = append(metrics, Metric{
+				FileName:      strings.Replace(column, " ", "_", -1),
+				YDiscription:  column,
+				Quantity:      units.Latency,
+				LowerIsBetter: true,
+				value: func(res *GroupResults) float64 {
+					if value, ok := res.Percentiles[column]; ok {
+						return value
+					}
+					return math.NaN()
+				},
+				units: opts.Units,
+			})
+		}
+	}
+	return metrics
+}
+
+// Value - value of metric from results in nanoseconds or bytes per second, NaN if it is missing in results
+func (m Metric) Value(res *GroupResults) float64 {
+	return m.value(res)
+}
+
+// Unit - unit of metric for values in nanoseconds or bytes per second:
+// unit set by user or unit chosen by range of values
+func (m Metric) Unit(values ...float64) units.Unit {
+	return m.units.Choose(m.Quantity, values...)
+}
+
+// ParseMixedMode - converts name of mode (split, total, both) to MixedMode
+func ParseMixedMode(name string) (MixedMode, error) {
+	switch name {
+	case "", "split":
+		return MixedSplit, nil
+	case "total":
+		return MixedTotal, nil
+	case "both":
+		return MixedBoth, nil
+	}
+	return MixedSplit, fmt.Errorf("unknown mode for mixed workloads: %s", name)
+}
 
 // Round - round performance value
 func Round(x float64) float64 {
@@ -82,8 +202,132 @@ func Round(x float64) float64 {
 	return t
 }
 
+// PatternName - name of pattern by rw, bs, iodepth and numjobs.
+// Direction is added only for jobs with mixed directions (Ex. "randrw-4k d=32 j=1 read").
+// Options.PatternName is used for reports, the key of patterns may be changed by options
+func PatternName(rw, bs, depth, jobs, direction string) string {
+	return formatPattern(patternTemplates[PatternKeyTuple],
+		PatternFields{RW: rw, BS: bs, IODepth: depth, NumJobs: jobs}, direction)
+}
+
+// patternFields - values of job for pattern key
+func (r *GroupResults) patternFields() PatternFields {
+	return PatternFields{
+		JobName:  r.JobName,
+		RW:       r.Pattern,
+		BS:       r.Bs,
+		IODepth:  r.Depth,
+		NumJobs:  r.JobsCount,
+		IOEngine: r.IOEngine,
+	}
+}
+
+// csvColumn - index of column in CSV table and unit of its values
+type csvColumn struct {
+	index int
+	unit  units.Unit
+}
+
+// csvColumns - columns of CSV table by names from header without units
+type csvColumns map[string]csvColumn
+
+// newCSVColumns - columns from header of CSV table
+func newCSVColumns(header []string) csvColumns {
+	var columns = make(csvColumns)
+	for index, title := range header {
+		name, unit := csvt.ParseHeader(title)
+		columns[name] = csvColumn{index: index, unit: unit}
+	}
+	return columns
+}
+
+// value - returns value of the column with name from line of CSV table
+func (c csvColumns) value(line []string, name string) string {
+	if column, ok := c[name]; ok && column.index < len(line) {
+		return line[column.index]
+	}
+	return ""
+}
+
+// floatOrNaN - returns value of the column as float64 or NaN if value is not available
+func (c csvColumns) floatOrNaN(line []string, name string) float64 {
+	value, err := strconv.ParseFloat(c.value(line, name), 64)
+	if err != nil {
+		return math.NaN()
+	}
+	return c[name].unit.Base(value)
+}
+
+// directionResults - get results for one direction from line of CSV table
+func (c csvColumns) directionResults(line []string, d bs.Direction, percentiles []string) GroupResults {
+	return c.results(line, d.String(), func(column string) string { return csvt.ColumnName(d, column) }, percentiles)
+}
+
+// totalResults - get combined results of all directions of job from line of CSV table.
+// Values which can not be combined are NaN
+func (c csvColumns) totalResults(line []string, percentiles []string) GroupResults {
+	return c.results(line, TotalDirection, csvt.TotalColumnName, percentiles)
+}
+
+// results - get results from columns of line of CSV table with names by column (Ex. csvt.ColPerformance)
+func (c csvColumns) results(line []string, direction string, name func(string) string, percentiles []string) GroupResults {
+	var percentilesValues = make(map[string]float64)
+	for _, column := range percentiles {
+		percentilesValues[column] = c.floatOrNaN(line, name(column))
+	}
+	return GroupResults{
+		JobName:     c.value(line, csvt.ColJobName),
+		GroupID:     c.value(line, csvt.ColGroupID),
+		Pattern:     c.value(line, csvt.ColPattern),
+		Bs:          c.value(line, csvt.ColBs),
+		Depth:       c.value(line, csvt.ColDepth),
+		JobsCount:   c.value(line, csvt.ColJobs),
+		IOEngine:    c.value(line, csvt.ColIOEngine),
+		Direction:   direction,
+		Performance: c.floatOrNaN(line, name(csvt.ColPerformance)),
+		BwMin:       c.floatOrNaN(line, name(csvt.ColBwMin)),
+		BwMax:       c.floatOrNaN(line, name(csvt.ColBwMax)),
+		IopsMin:     c.floatOrNaN(line, name(csvt.ColIopsMin)),
+		IopsMax:     c.floatOrNaN(line, name(csvt.ColIopsMax)),
+		LatMin:      c.floatOrNaN(line, name(csvt.ColLatMin)),
+		LatMax:      c.floatOrNaN(line, name(csvt.ColLatMax)),
+		LatStd:      c.floatOrNaN(line, name(csvt.ColLatStd)),
+		Percentiles: percentilesValues,
+	}
+}
+
+// jobResults - results of the job which will be reported according to the mode for mixed jobs
+func jobResults(directions []GroupResults, total GroupResults, mode MixedMode) []GroupResults {
+	if len(directions) == 1 || mode == MixedSplit {
+		return directions
+	}
+	if mode == MixedTotal {
+		return []GroupResults{total}
+	}
+	return append(directions, total)
+}
+
+// uniquePatterns - results of test with one job for each pattern. Jobs of one test with the same pattern
+// are not repetitions (Ex. numjobs without group_reporting), only the first of them is reported.
+// Returns also descriptions of skipped jobs
+func uniquePatterns(results GroupTestRes) (GroupTestRes, []string) {
+	var unique GroupTestRes
+	var duplicates []string
+	jobs := make(map[string]string)
+	for _, result := range results {
+		if job, ok := jobs[result.Pattern]; ok {
+			duplicates = append(duplicates, fmt.Sprintf("job [%s] has the same pattern [%s] as job [%s]",
+				result.GroupRes.JobName, result.Pattern, job))
+			continue
+		}
+		jobs[result.Pattern] = result.GroupRes.JobName
+		unique = append(unique, result)
+	}
+	return unique, duplicates
+}
+
 // ParsingCSVfile - Parsing CSV file
-func (t *AllResults) ParsingCSVfile(fullPathToCsv string) error {
+func (t *AllResults) ParsingCSVfile(fullPathToCsv string, opts Options) error {
 	csvfileName := filepath.Base(fullPathToCsv)
 	var groupFile = make(GroupTestRes, 0)
 	csvFile, err := os.Open(fullPathToCsv)
@@ -96,140 +340,220 @@ func (t *AllResults) ParsingCSVfile(fullPathToCsv string) error {
 	if err != nil {
 		return fmt.Errorf("error: %v", err)
 	}
+	if len(reader) == 0 {
+		return fmt.Errorf("error: CSV file %s is empty", fullPathToCsv)
+	}
 
-	for iter, line := range reader {
-		if iter == 0 {
-			continue
+	var percentiles = opts.latencyPercentiles().Columns()
+	var columns = newCSVColumns(reader[0])
+
+	for _, line := range reader[1:] {
+		var directions []GroupResults
+		for _, d := range bs.JobDirections(columns.value(line, csvt.ColPattern)) {
+			directions = append(directions, columns.directionResults(line, d, percentiles))
 		}
-		pIopsMin, _ := strconv.Atoi(line[9])
-		pIopsMax, _ := strconv.Atoi(line[10])
-		pLatMin, _ := strconv.ParseFloat(line[11], 64)
-		pLatMax, _ := strconv.ParseFloat(line[12], 64)
-		pLatStd, _ := strconv.ParseFloat(line[13], 64)
-		pLatP, _ := strconv.ParseFloat(line[14], 64)
-		resultOneGroup := GroupResults{
-			JobName:	 line[0],
-			GroupID:     line[1],
-			Pattern:     line[2],
-			Bs:          line[3],
-			Depth:       line[4],
-			JobsCount:   line[5],
-			Performance: line[6],
-			BwMin:       line[7],
-			BwMax:       line[8],
-			IopsMin:     pIopsMin,
-			IopsMax:     pIopsMax,
-			LatMin:      pLatMin,
-			LatMax:      pLatMax,
-			LatStd:      pLatStd,
-			CLatPercent: pLatP,
-		}
-		group := TestResult{
-			GroupRes: resultOneGroup,
-			Pattern: fmt.Sprintf("%s-%s d=%s j=%s", //change on only JobName later when will add more info in legend
-				resultOneGroup.Pattern,
-				resultOneGroup.Bs,
-				resultOneGroup.Depth,
-				resultOneGroup.JobsCount),
-		}
-		groupFile = append(groupFile, &group)
-	}
-	testN := strings.Split(csvfileName, ".")
+
+		total := columns.totalResults(line, percentiles)
+		for _, resultOneGroup := range jobResults(directions, total, opts.Mixed) {
+			direction := ""
+			if len(directions) > 1 {
+				direction = resultOneGroup.Direction
+			}
+			group := TestResult{
+				GroupRes: resultOneGroup,
+				Pattern:  opts.PatternName(resultOneGroup.patternFields(), direction),
+			}
+			groupFile = append(groupFile, &group)
+		}
+	}
+	// the same name as in bsdata.ReadAllFiles, names of tests may have dots (Ex. "TestA.v2")
+	testName := strings.TrimSuffix(csvfileName, filepath.Ext(csvfileName))
+	unique, duplicates := uniquePatterns(groupFile)
 	finishRes := ListAllResults{
-		IOTestResults: groupFile,
+		IOTestResults: unique,
+		Duplicates:    duplicates,
 		FileName:      fullPathToCsv,
-		TestName:      testN[0],
+		TestName:      testName,
+		Group:         opts.GroupName(testName),
 	}
 	*t = append(*t, &finishRes)
 	return nil
 }
 
-//GetIdenticalPatterns - search for identical results patterns
-func GetIdenticalPatterns(groups AllResults) ([]string, error) {
-	var allPattern []string
+// groupName - name of test in reports
+func (r *ListAllResults) groupName() string {
+	if r.Group == "" {
+		return r.TestName
+	}
+	return r.Group
+}
+
+// testPatterns - count of tests for each pattern
+func testPatterns(groups AllResults) map[string]int {
 	uniq := make(map[string]int)
 	for _, group := range groups {
-		/* This output of a list of files is necessary to
-		* understand which files we are transferring for
-		* comparison. Sometimes it happens that there
-		* are hidden temporary files in the folder! */
+		patterns := make(map[string]bool)
 		for _, result := range group.IOTestResults {
-			uniq[result.Pattern]++
+			patterns[result.Pattern] = true
+		}
+		for pattern := range patterns {
+			uniq[pattern]++
 		}
 	}
-	for key, val := range uniq {
+	return uniq
+}
+
+//GetIdenticalPatterns - search for identical results patterns
+func GetIdenticalPatterns(groups AllResults) ([]string, error) {
+	var allPattern []string
+	for key, val := range testPatterns(groups) {
 		if val == len(groups) {
 			allPattern = append(allPattern, key)
 		}
 	}
 
 	if len(allPattern) == 0 {
-		return nil, fmt.Errorf("error: the number of files is more than necessary")
+		var tests []string
+		for _, group := range groups {
+			tests = append(tests, group.TestName)
+		}
+		return nil, fmt.Errorf("there are no patterns which are in all tests (%s), "+
+			"patterns which are only in some tests are reported with --partial", strings.Join(tests, ", "))
 	}
+	SortPatterns(allPattern, groups, nil)
 	return allPattern, nil
 }
 
-//GetPatternTable - gets patterns based structures
-func (t *PatternsTable) GetPatternTable(identicalPattern []string, results AllResults, valueType uint16) {
+// GetAllPatterns - all patterns which are at least in one test
+func GetAllPatterns(groups AllResults) ([]string, error) {
+	var allPattern []string
+	for key := range testPatterns(groups) {
+		allPattern = append(allPattern, key)
+	}
+	if len(allPattern) == 0 {
+		return nil, fmt.Errorf("there are no results of jobs in tests")
+	}
+	SortPatterns(allPattern, groups, nil)
+	return allPattern, nil
+}
+
+// patternKeys - rw, block size, iodepth, numjobs and direction of mixed jobs for each pattern
+func patternKeys(groups AllResults) map[string]bs.PatternKey {
+	keys := make(map[string]bs.PatternKey)
+	for _, group := range groups {
+		for _, result := range group.IOTestResults {
+			res := &result.GroupRes
+			key := bs.PatternKey{RW: res.Pattern, BS: res.Bs, IODepth: res.Depth, NumJobs: res.JobsCount}
+			if len(bs.JobDirections(res.Pattern)) > 1 {
+				key.Direction = res.Direction
+			}
+			keys[result.Pattern] = key
+		}
+	}
+	return keys
+}
+
+// SortPatterns - sort patterns by rw, block size, iodepth and numjobs in order of keys
+// (bs.DefaultSortOrder if order is empty), keys of patterns are taken from results of tests
+func SortPatterns(patterns []string, groups AllResults, order []string) {
+	keys := patternKeys(groups)
+	sort.SliceStable(patterns, func(i, j int) bool {
+		if result := bs.ComparePatterns(keys[patterns[i]], keys[patterns[j]], order); result != 0 {
+			return result < 0
+		}
+		return patterns[i] < patterns[j]
+	})
+}
+
+// GetPatterns - patterns for reports: patterns which are in all tests
+// or with Options.Partial all patterns, missing results of tests are NaN
+func GetPatterns(groups AllResults, opts Options) ([]string, error) {
+	var patterns []string
+	var err error
+	if opts.Partial {
+		patterns, err = GetAllPatterns(groups)
+	} else {
+		patterns, err = GetIdenticalPatterns(groups)
+	}
+	if err != nil {
+		return nil, err
+	}
+	SortPatterns(patterns, groups, opts.SortOrder)
+	return patterns, nil
+}
+
+//GetPatternTable - gets patterns based structures.
+//Repetitions of one test are reported as one legend with mean value and statistics,
+//each test of group is one repetition with one value of pattern
+func (t *PatternsTable) GetPatternTable(identicalPattern []string, results AllResults, metric Metric) {
+	t.baseTable(identicalPattern, results, metric)
+	t.setUnit(metric)
+}
+
+// baseTable - the same table as GetPatternTable with values and statistics in nanoseconds
+// or bytes per second
+func (t *PatternsTable) baseTable(identicalPattern []string, results AllResults, metric Metric) {
 	for _, ipattern := range identicalPattern {
 		fTable := AllPatternResults{
-			PatternName: ipattern,
+			PatternName:  ipattern,
+			YDiscription: metric.YDiscription,
+			FileName:     metric.FileName,
 		}
 		*t = append(*t, &fTable)
 	}
 
+	// all tests are in each row, values of tests without pattern are NaN
+	var groups []string
+	known := make(map[string]bool)
+	for _, test := range results {
+		group := test.groupName()
+		if !known[group] {
+			known[group] = true
+			groups = append(groups, group)
+		}
+	}
+
 	for _, stroka := range *t {
+		groupValues := make(map[string][]float64)
 		for _, test := range results {
+			group := test.groupName()
 			for _, pattern := range test.IOTestResults {
 				if pattern.Pattern == stroka.PatternName {
-					var value float64
-					switch val := valueType; val {
-					case performance:
-						tmpBw, _ := strconv.ParseFloat(pattern.GroupRes.Performance, 64)
-						value = Round(tmpBw)
-						stroka.YDiscription = "Mb/s"
-						stroka.FileName = "Performance"
-					case minIOPS:
-						value = float64(pattern.GroupRes.IopsMin)
-						stroka.YDiscription = "IOPS min"
-						stroka.FileName = "IOPS_min_value"
-					case maxIOPS:
-						value = float64(pattern.GroupRes.IopsMax)
-						stroka.YDiscription = "IOPS max"
-						stroka.FileName = "IOPS_max_value"
-					case minBW:
-						tmpBwMin, _ := strconv.ParseFloat(pattern.GroupRes.BwMin, 64)
-						value = Round(tmpBwMin)
-						stroka.YDiscription = "BW Min (MB/s)"
-						stroka.FileName = "BW_min_value"
-					case maxBW:
-						tmpBwMax, _ := strconv.ParseFloat(pattern.GroupRes.BwMax, 64)
-						value = Round(tmpBwMax)
-						stroka.YDiscription = "BW Max (MB/s)"
-						stroka.FileName = "BW_max_value"
-					case minLat:
-						value = float64(pattern.GroupRes.LatMin)
-						stroka.YDiscription = "Latency min (ms)"
-						stroka.FileName = "Latency_min_value"
-					case maxLat:
-						value = float64(pattern.GroupRes.LatMax)
-						stroka.YDiscription = "Latency max (ms)"
-						stroka.FileName = "Latency_max_value"
-					case stdLat:
-						value = float64(pattern.GroupRes.LatStd)
-						stroka.YDiscription = "Latency stddev (ms)"
-						stroka.FileName = "Latency_stdev"
-					case p99Lat:
-						value = float64(pattern.GroupRes.CLatPercent)
-						stroka.YDiscription = "cLatency p99 (ms)"
-						stroka.FileName = "Latency_p99"
-					default:
-						fmt.Println("Error with options")
-					}
-					stroka.Values = append(stroka.Values, value)
-					stroka.Legends = append(stroka.Legends, test.TestName)
+					groupValues[group] = append(groupValues[group], metric.Value(&pattern.GroupRes))
+					break
 				}
 			}
 		}
+		for _, group := range groups {
+			stats := CalcStats(groupValues[group])
+			stroka.Legends = append(stroka.Legends, group)
+			stroka.Values = append(stroka.Values, stats.Mean)
+			stroka.Stats = append(stroka.Stats, stats)
+		}
+	}
+}
+
+// setUnit - convert values and statistics of all patterns of table from nanoseconds or bytes
+// per second to one unit of metric, it is chosen by range of values of table
+func (t PatternsTable) setUnit(metric Metric) {
+	var values []float64
+	for _, pattern := range t {
+		values = append(values, pattern.Values...)
+	}
+	unit := metric.Unit(values...)
+	for _, pattern := range t {
+		pattern.Unit = unit
+		pattern.YDiscription = unit.Label(metric.YDiscription)
+		for i := range pattern.Values {
+			pattern.Values[i] = unit.Value(pattern.Values[i])
+		}
+		for i := range pattern.Stats {
+			stats := &pattern.Stats[i]
+			stats.Mean = unit.Value(stats.Mean)
+			stats.Stddev = unit.Value(stats.Stddev)
+			stats.Min = unit.Value(stats.Min)
+			stats.Max = unit.Value(stats.Max)
+			stats.CI95 = unit.Value(stats.CI95)
+		}
 	}
 }
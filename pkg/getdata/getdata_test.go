@@ -0,0 +1,142 @@
+package getdata
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+
+	bs "github.com/vk-en/fioplot-bs/pkg/bsdata"
+	csvt "github.com/vk-en/fioplot-bs/pkg/csvtable"
+	"github.com/vk-en/fioplot-bs/pkg/units"
+)
+
+// testJob - job of one direction with bandwidth in KiB/s
+func testJob(name, rw, depth, numjobs string, bw int) bs.Jobs {
+	job := bs.Jobs{
+		TestName:   name,
+		TestOption: bs.JobOptions{RW: rw, BS: "4k", IODepth: depth, NumJobs: numjobs, Ioengine: "libaio"},
+	}
+	job.Operation(bs.JobDirections(rw)[0]).Bw = bw
+	return job
+}
+
+// writeJobsCSV - CSV table of test with jobs
+func writeJobsCSV(t *testing.T, dir, test string, jobs ...bs.Jobs) string {
+	t.Helper()
+	path := filepath.Join(dir, test+".csv")
+	if err := csvt.ConvertJSONtoCSV(bs.FioJSON{Jobs: jobs}, path, csvt.Options{}); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// writeTestCSV - CSV table of test with randread jobs with bandwidth in KiB/s
+func writeTestCSV(t *testing.T, dir, test string, jobs map[string]int) string {
+	t.Helper()
+	var fioJobs []bs.Jobs
+	for _, name := range []string{"a", "b"} {
+		if bw, ok := jobs[name]; ok {
+			fioJobs = append(fioJobs, testJob(name, "randread", "32", "1", bw))
+		}
+	}
+	return writeJobsCSV(t, dir, test, fioJobs...)
+}
+
+// TestGetPatternTableGroups - tests of group are repetitions of pattern, jobs of one test with the same pattern are not.
+// Values are read from CSV tables without rounding, names of tests may have dots
+func TestGetPatternTableGroups(t *testing.T) {
+	dir := t.TempDir()
+	files := []string{
+		writeTestCSV(t, dir, "TestA.run1", map[string]int{"a": 1000, "b": 5000}),
+		writeTestCSV(t, dir, "TestA.run2", map[string]int{"a": 3001}),
+		writeTestCSV(t, dir, "TestB.v2", map[string]int{"b": 4000}),
+	}
+	opts := Options{Groups: map[string]string{"TestA.run1": "TestA", "TestA.run2": "TestA"}}
+
+	var results AllResults
+	for _, file := range files {
+		if err := results.ParsingCSVfile(file, opts); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(results[0].IOTestResults) != 1 || results[0].IOTestResults[0].GroupRes.JobName != "a" {
+		t.Fatalf("expected only the first job of duplicated pattern, got %d results", len(results[0].IOTestResults))
+	}
+	if coverage := GetCoverage(results, opts); len(coverage.Duplicates) != 1 {
+		t.Errorf("expected one duplicated job in coverage, got %v", coverage.Duplicates)
+	}
+
+	patterns, err := GetPatterns(results, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kib, err := units.Parse("KiB/s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var table PatternsTable
+	table.GetPatternTable(patterns, results, Metrics(Options{Units: kib})[0])
+	if len(table) != 1 || len(table[0].Legends) != 2 {
+		t.Fatalf("expected one pattern with tests TestA and TestB.v2, got %+v", table)
+	}
+	for i, want := range []struct {
+		legend string
+		runs   int
+		mean   float64
+	}{{"TestA", 2, 2000.5}, {"TestB.v2", 1, 4000}} {
+		pattern := table[0]
+		if pattern.Legends[i] != want.legend || pattern.Stats[i].Runs != want.runs || pattern.Values[i] != want.mean {
+			t.Errorf("expected %s with %d runs and mean %v, got %s with %d runs and mean %v", want.legend,
+				want.runs, want.mean, pattern.Legends[i], pattern.Stats[i].Runs, pattern.Values[i])
+		}
+	}
+}
+
+// TestTotalResults - combined results of directions of mixed job are calculated from fio results,
+// values which can not be combined are NaN
+func TestTotalResults(t *testing.T) {
+	percentiles := csvt.LatencyPercentiles{Latencies: []string{"clat"}, Percentiles: []float64{99, 99.9}}
+	var fio bs.FioJSON
+	fio.Jobs = append(fio.Jobs, bs.Jobs{
+		TestName:   "mixed",
+		TestOption: bs.JobOptions{RW: "randrw", BS: "4k", IODepth: "32", NumJobs: "1", Ioengine: "libaio"},
+		Read: bs.OperationRW{Bw: 1000, BwMin: 900, BwMax: 1100, IopsMin: 200, IopsMax: 300, TotalIos: 100,
+			LatNS:  bs.LatNS{Min: 10, Max: 100, Mean: 50, Stddev: 10},
+			ClatNS: bs.LatNS{Bins: map[string]int64{"40": 99, "1000": 1}}},
+		Write: bs.OperationRW{Bw: 3000, BwMin: 2900, BwMax: 3100, IopsMin: 700, IopsMax: 800, TotalIos: 300,
+			LatNS:  bs.LatNS{Min: 5, Max: 200, Mean: 90, Stddev: 20},
+			ClatNS: bs.LatNS{Bins: map[string]int64{"60": 297, "2000": 3}}},
+	})
+	path := filepath.Join(t.TempDir(), "TestA.csv")
+	if err := csvt.ConvertJSONtoCSV(fio, path, csvt.Options{Percentiles: percentiles}); err != nil {
+		t.Fatal(err)
+	}
+	var results AllResults
+	if err := results.ParsingCSVfile(path, Options{Mixed: MixedTotal, Percentiles: percentiles}); err != nil {
+		t.Fatal(err)
+	}
+	if len(results[0].IOTestResults) != 1 {
+		t.Fatalf("expected one pattern of combined results, got %d", len(results[0].IOTestResults))
+	}
+	total := results[0].IOTestResults[0].GroupRes
+	if total.Direction != TotalDirection || total.Performance != 4000*units.KiB {
+		t.Errorf("expected bandwidth %v of direction %s, got %v of %s", 4000*units.KiB, TotalDirection,
+			total.Performance, total.Direction)
+	}
+	for name, value := range map[string]float64{"BW min": total.BwMin, "BW max": total.BwMax,
+		"IOPS min": total.IopsMin, "IOPS max": total.IopsMax} {
+		if !math.IsNaN(value) {
+			t.Errorf("%s of directions can not be combined, got %v", name, value)
+		}
+	}
+	// mean of latencies is 80, stddev is pooled from (n-1)*stddev^2 and n*(mean-80)^2 of directions
+	if total.LatMin != 5 || total.LatMax != 200 || math.Abs(total.LatStd-math.Sqrt(249500.0/399)) > 1e-9 {
+		t.Errorf("expected latency min 5, max 200, stddev %v, got %v, %v, %v", math.Sqrt(249500.0/399),
+			total.LatMin, total.LatMax, total.LatStd)
+	}
+	for column, want := range map[string]float64{"cLatency p99": 60, "cLatency p99.9": 2000} {
+		if total.Percentiles[column] != want {
+			t.Errorf("expected %s %v from merged bins, got %v", column, want, total.Percentiles[column])
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package getdata
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+)
+
+// GroupsByRegexp - groups of tests by regular expression. The name of group is the submatch
+// named "test", the first submatch or the whole match (Ex. "^(.*)-run\d+$" folds
+// TestA-run1, TestA-run2 into TestA). Tests which don't match are not grouped
+func GroupsByRegexp(testNames []string, expr string) (map[string]string, error) {
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regular expression for groups of tests: %w", err)
+	}
+
+	var groups = make(map[string]string)
+	submatch := 0
+	if index := re.SubexpIndex("test"); index > 0 {
+		submatch = index
+	} else if re.NumSubexp() > 0 {
+		submatch = 1
+	}
+	for _, name := range testNames {
+		if match := re.FindStringSubmatch(name); match != nil && match[submatch] != "" {
+			groups[name] = match[submatch]
+		}
+	}
+	return groups, nil
+}
+
+// GroupsFromFile - groups of tests from JSON file with names of tests for each group
+// (Ex. {"TestA": ["TestA-run1", "TestA-run2"], "TestB": ["TestB-first", "TestB-second"]})
+func GroupsFromFile(path string) (map[string]string, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read file with groups of tests: %w", err)
+	}
+
+	var sidecar map[string][]string
+	if err := json.Unmarshal(content, &sidecar); err != nil {
+		return nil, fmt.Errorf("invalid file with groups of tests [%s]: %w", path, err)
+	}
+
+	var groups = make(map[string]string)
+	for group, tests := range sidecar {
+		for _, test := range tests {
+			if previous, ok := groups[test]; ok && previous != group {
+				return nil, fmt.Errorf("test [%s] is in groups [%s] and [%s]", test, previous, group)
+			}
+			groups[test] = group
+		}
+	}
+	return groups, nil
+}
@@ -0,0 +1,104 @@
+package getdata
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Named keys of patterns, other keys are templates (Ex. "{rw}-{bs}-qd{iodepth}")
+const (
+	PatternKeyTuple  = "tuple"  // rw, block size, iodepth and numjobs (Ex. "randread-4k d=32 j=1")
+	PatternKeyJob    = "job"    // name of job, jobs of tests are matched by names
+	PatternKeyEngine = "engine" // tuple with ioengine (Ex. "libaio randread-4k d=32 j=1")
+)
+
+// patternTemplates - templates of named keys of patterns
+var patternTemplates = map[string]string{
+	PatternKeyTuple:  "{rw}-{bs} d={iodepth} j={numjobs}",
+	PatternKeyJob:    "{job}",
+	PatternKeyEngine: "{ioengine} {rw}-{bs} d={iodepth} j={numjobs}",
+}
+
+// rePatternField - field of template of pattern key (Ex. "{iodepth}")
+var rePatternField = regexp.MustCompile(`\{([^{}]*)\}`)
+
+// PatternFields - values of job which can be used in pattern keys
+type PatternFields struct {
+	JobName  string
+	RW       string
+	BS       string
+	IODepth  string
+	NumJobs  string
+	IOEngine string
+}
+
+// patternFieldNames - names of fields in templates of pattern keys
+var patternFieldNames = []string{"job", "rw", "bs", "iodepth", "numjobs", "ioengine"}
+
+// value - value of field by name from template
+func (f PatternFields) value(name string) (string, bool) {
+	switch name {
+	case "job":
+		return f.JobName, true
+	case "rw":
+		return f.RW, true
+	case "bs":
+		return f.BS, true
+	case "iodepth":
+		return f.IODepth, true
+	case "numjobs":
+		return f.NumJobs, true
+	case "ioengine":
+		return f.IOEngine, true
+	}
+	return "", false
+}
+
+// ParsePatternKey - template of pattern key by name (tuple, job, engine)
+// or user template with fields in braces (Ex. "{rw}-{bs}-qd{iodepth}")
+func ParsePatternKey(key string) (string, error) {
+	if template, ok := patternTemplates[strings.ToLower(key)]; ok {
+		return template, nil
+	}
+	fields := rePatternField.FindAllStringSubmatch(key, -1)
+	if len(fields) == 0 {
+		var names []string
+		for name := range patternTemplates {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return "", fmt.Errorf("unknown pattern key: %s (keys: %s or template with fields in braces, Ex. {rw}-{bs}-qd{iodepth})",
+			key, strings.Join(names, ", "))
+	}
+	for _, field := range fields {
+		if _, ok := (PatternFields{}).value(field[1]); !ok {
+			return "", fmt.Errorf("unknown field of pattern key template: {%s} (fields: {%s})",
+				field[1], strings.Join(patternFieldNames, "}, {"))
+		}
+	}
+	return key, nil
+}
+
+// formatPattern - name of pattern by template, direction is added only for jobs with mixed directions
+func formatPattern(template string, fields PatternFields, direction string) string {
+	pattern := rePatternField.ReplaceAllStringFunc(template, func(field string) string {
+		value, _ := fields.value(field[1 : len(field)-1])
+		return value
+	})
+	if direction != "" {
+		pattern = fmt.Sprintf("%s %s", pattern, direction)
+	}
+	return pattern
+}
+
+// PatternName - name of pattern by key from options (rw, bs, iodepth and numjobs by default)
+// which is used to match jobs between tests
+func (o Options) PatternName(fields PatternFields, direction string) string {
+	template := o.PatternKey
+	if template == "" {
+		template = patternTemplates[PatternKeyTuple]
+	}
+	return formatPattern(template, fields, direction)
+}
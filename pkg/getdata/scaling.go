@@ -0,0 +1,109 @@
+package getdata
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+
+	bs "github.com/vk-en/fioplot-bs/pkg/bsdata"
+)
+
+// Axes of scaling tables
+const (
+	ScalingIODepth = "iodepth"
+	ScalingNumJobs = "numjobs"
+)
+
+// ScalingAxes - all axes of scaling tables
+var ScalingAxes = []string{ScalingIODepth, ScalingNumJobs}
+
+// ScalingTable - values of metric for patterns with the same rw, block size and direction
+// by iodepth or by numjobs, the other of them is the same for all patterns of table
+type ScalingTable struct {
+	Name         string  // Ex. "randread-4k j=1" for iodepth axis, "randread-4k d=32" for numjobs axis
+	Axis         string  // ScalingIODepth or ScalingNumJobs
+	X            []int64 // values of iodepth or numjobs in ascending order
+	Legends      []string
+	Values       [][]float64 // values of legends for each X, NaN if test has no result
+	CI           [][]float64 // half-width of 95% confidence interval of values, NaN without statistics
+	YDiscription string
+	FileName     string
+}
+
+// ScalingName - name of scaling table for pattern and value of pattern on axis
+func ScalingName(key bs.PatternKey, axis string) (string, string) {
+	name := fmt.Sprintf("%s-%s", key.RW, key.BS)
+	value := key.IODepth
+	if axis == ScalingIODepth {
+		name = fmt.Sprintf("%s j=%s", name, key.NumJobs)
+	} else {
+		name = fmt.Sprintf("%s d=%s", name, key.IODepth)
+		value = key.NumJobs
+	}
+	if key.Direction != "" {
+		name = fmt.Sprintf("%s %s", name, key.Direction)
+	}
+	return name, value
+}
+
+// GetScalingTables - tables of metric by iodepth or numjobs from table of patterns.
+// Tables are in order of patterns, tables with less than two values on axis are skipped
+func (t PatternsTable) GetScalingTables(results AllResults, axis string) []*ScalingTable {
+	keys := patternKeys(results)
+	var tables []*ScalingTable
+	byName := make(map[string]*ScalingTable)
+	rows := make(map[string]map[int64]*AllPatternResults)
+	for _, pattern := range t {
+		key, ok := keys[pattern.PatternName]
+		if !ok {
+			continue
+		}
+		name, value := ScalingName(key, axis)
+		x, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			continue
+		}
+		table, ok := byName[name]
+		if !ok {
+			table = &ScalingTable{
+				Name:         name,
+				Axis:         axis,
+				Legends:      pattern.Legends,
+				YDiscription: pattern.YDiscription,
+				FileName:     pattern.FileName,
+			}
+			byName[name] = table
+			rows[name] = make(map[int64]*AllPatternResults)
+			tables = append(tables, table)
+		}
+		// jobs with different names and the same load are matched by names, the first one is taken
+		if _, ok := rows[name][x]; ok {
+			continue
+		}
+		rows[name][x] = pattern
+		table.X = append(table.X, x)
+	}
+
+	var scaling []*ScalingTable
+	for _, table := range tables {
+		if len(table.X) < 2 {
+			continue
+		}
+		sort.Slice(table.X, func(i, j int) bool { return table.X[i] < table.X[j] })
+		for _, x := range table.X {
+			pattern := rows[table.Name][x]
+			ci := make([]float64, len(pattern.Values))
+			for index := range ci {
+				ci[index] = math.NaN()
+				if index < len(pattern.Stats) {
+					ci[index] = pattern.Stats[index].CI95
+				}
+			}
+			table.Values = append(table.Values, pattern.Values)
+			table.CI = append(table.CI, ci)
+		}
+		scaling = append(scaling, table)
+	}
+	return scaling
+}
@@ -0,0 +1,78 @@
+package getdata
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/vk-en/fioplot-bs/pkg/units"
+)
+
+func TestGetScalingTables(t *testing.T) {
+	dir := t.TempDir()
+	files := []string{
+		writeJobsCSV(t, dir, "TestA",
+			testJob("d32", "randread", "32", "1", 300),
+			testJob("d1", "randread", "1", "1", 100),
+			testJob("d8", "randread", "8", "1", 200),
+			testJob("d8j4", "randread", "8", "4", 400),
+			testJob("write", "randwrite", "1", "1", 50)),
+		writeJobsCSV(t, dir, "TestB",
+			testJob("d8", "randread", "8", "1", 210),
+			testJob("d1", "randread", "1", "1", 110),
+			testJob("d8j4", "randread", "8", "4", 410),
+			testJob("write", "randwrite", "1", "1", 60)),
+	}
+	bytes, err := units.Parse("B/s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := Options{Partial: true, Units: bytes}
+	var results AllResults
+	for _, file := range files {
+		if err := results.ParsingCSVfile(file, opts); err != nil {
+			t.Fatal(err)
+		}
+	}
+	patterns, err := GetPatterns(results, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var table PatternsTable
+	table.GetPatternTable(patterns, results, Metrics(opts)[0])
+
+	kib := float64(units.KiB)
+	nan := math.NaN()
+	var tests = []struct {
+		axis   string
+		name   string
+		x      []int64
+		values [][]float64
+	}{
+		// TestB has no result for iodepth 32, randwrite and numjobs 4 have only one point
+		{ScalingIODepth, "randread-4k j=1", []int64{1, 8, 32}, [][]float64{{100 * kib, 110 * kib}, {200 * kib, 210 * kib}, {300 * kib, nan}}},
+		{ScalingNumJobs, "randread-4k d=8", []int64{1, 4}, [][]float64{{200 * kib, 210 * kib}, {400 * kib, 410 * kib}}},
+	}
+	for _, test := range tests {
+		tables := table.GetScalingTables(results, test.axis)
+		if len(tables) != 1 {
+			t.Errorf("%s: expected one table, got %d", test.axis, len(tables))
+			continue
+		}
+		scaling := tables[0]
+		if scaling.Name != test.name || !reflect.DeepEqual(scaling.X, test.x) ||
+			!reflect.DeepEqual(scaling.Legends, []string{"TestA", "TestB"}) {
+			t.Errorf("%s: expected table %s by %v, got %s by %v with legends %v", test.axis, test.name, test.x,
+				scaling.Name, scaling.X, scaling.Legends)
+			continue
+		}
+		for i := range test.values {
+			for legend, want := range test.values[i] {
+				got := scaling.Values[i][legend]
+				if got != want && !(math.IsNaN(got) && math.IsNaN(want)) {
+					t.Errorf("%s: value of %s at %d is %v, expected %v", test.name, scaling.Legends[legend], scaling.X[i], got, want)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package getdata
+
+import (
+	"encoding/csv"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+
+	csvt "github.com/vk-en/fioplot-bs/pkg/csvtable"
+	"github.com/vk-en/fioplot-bs/pkg/units"
+)
+
+// studentT975 - critical values of Student's t-distribution (two-sided 95%) for 1..30 degrees of freedom
+var studentT975 = []float64{
+	12.706, 4.303, 3.182, 2.776, 2.571, 2.447, 2.365, 2.306, 2.262, 2.228,
+	2.201, 2.179, 2.160, 2.145, 2.131, 2.120, 2.110, 2.101, 2.093, 2.086,
+	2.080, 2.074, 2.069, 2.064, 2.060, 2.056, 2.052, 2.048, 2.045, 2.042,
+}
+
+// Stats - statistics of metric over repetitions of one test
+type Stats struct {
+	Runs   int // count of repetitions with value
+	Mean   float64
+	Stddev float64 // sample standard deviation
+	Min    float64
+	Max    float64
+	CI95   float64 // half-width of 95% confidence interval of mean
+}
+
+// CalcStats - statistics of values, NaN values are skipped.
+// All statistics are NaN if there are no values
+func CalcStats(values []float64) Stats {
+	stats := Stats{
+		Mean: math.NaN(), Stddev: math.NaN(), Min: math.NaN(), Max: math.NaN(), CI95: math.NaN(),
+	}
+	var sum float64
+	for _, value := range values {
+		if math.IsNaN(value) {
+			continue
+		}
+		if stats.Runs == 0 || value < stats.Min {
+			stats.Min = value
+		}
+		if stats.Runs == 0 || value > stats.Max {
+			stats.Max = value
+		}
+		sum += value
+		stats.Runs++
+	}
+	if stats.Runs == 0 {
+		return stats
+	}
+	stats.Mean = sum / float64(stats.Runs)
+	if stats.Runs == 1 {
+		stats.Stddev, stats.CI95 = 0, 0
+		return stats
+	}
+
+	var squares float64
+	for _, value := range values {
+		if !math.IsNaN(value) {
+			squares += (value - stats.Mean) * (value - stats.Mean)
+		}
+	}
+	stats.Stddev = math.Sqrt(squares / float64(stats.Runs-1))
+	t := 1.96
+	if stats.Runs-1 <= len(studentT975) {
+		t = studentT975[stats.Runs-2]
+	}
+	stats.CI95 = t * stats.Stddev / math.Sqrt(float64(stats.Runs))
+	return stats
+}
+
+// HasRepetitions - true if any test in table has more than one run
+func (t PatternsTable) HasRepetitions() bool {
+	for _, pattern := range t {
+		for _, stats := range pattern.Stats {
+			if stats.Runs > 1 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// formatStat - value in base units for CSV table with full precision, missing value is "n/a"
+func formatStat(value float64) string {
+	if math.IsNaN(value) {
+		return csvt.NotAvailable
+	}
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+// CreateStatsCSV - create CSV table with statistics of all metrics over repetitions of tests.
+// As in CSV tables of tests values are in base units without rounding, the unit is in the name of metric
+// (Ex. "Latency min (ns)")
+func CreateStatsCSV(csvFiles []string, pathToCsv string, opts Options) error {
+	var testResults = make(AllResults, 0)
+	for _, file := range csvFiles {
+		if err := testResults.ParsingCSVfile(file, opts); err != nil {
+			return fmt.Errorf("parsing csv file [%s] failed: %w", file, err)
+		}
+	}
+
+	identicalPatterns, err := GetPatterns(testResults, opts)
+	if err != nil {
+		return fmt.Errorf("could not get patterns for report: %w", err)
+	}
+
+	var records = [][]string{{"Test", "Pattern", "Metric", "Runs", "Mean", "Stddev", "Min", "Max", "CI95 low", "CI95 high"}}
+	for _, metric := range Metrics(opts) {
+		var pTable = make(PatternsTable, 0)
+		pTable.baseTable(identicalPatterns, testResults, metric)
+		name := units.BaseUnit(metric.Quantity).Label(metric.YDiscription)
+		for _, pattern := range pTable {
+			for i, stats := range pattern.Stats {
+				records = append(records, []string{
+					pattern.Legends[i], pattern.PatternName, name, strconv.Itoa(stats.Runs),
+					formatStat(stats.Mean), formatStat(stats.Stddev), formatStat(stats.Min), formatStat(stats.Max),
+					formatStat(stats.Mean - stats.CI95), formatStat(stats.Mean + stats.CI95),
+				})
+			}
+		}
+	}
+
+	csvFile, err := os.Create(pathToCsv)
+	if err != nil {
+		return fmt.Errorf("could not create CSV file [%s]: %w", pathToCsv, err)
+	}
+	defer csvFile.Close()
+
+	writer := csv.NewWriter(csvFile)
+	if err := writer.WriteAll(records); err != nil {
+		return fmt.Errorf("could not write CSV file [%s]: %w", pathToCsv, err)
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package getdata
+
+import (
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestCreateStatsCSV - statistics of repetitions are written in base units without rounding
+func TestCreateStatsCSV(t *testing.T) {
+	dir := t.TempDir()
+	files := []string{
+		writeTestCSV(t, dir, "TestA.run1", map[string]int{"a": 1000}),
+		writeTestCSV(t, dir, "TestA.run2", map[string]int{"a": 3001}),
+	}
+	opts := Options{Groups: map[string]string{"TestA.run1": "TestA", "TestA.run2": "TestA"}}
+	path := filepath.Join(dir, "repetitions-stats.csv")
+	if err := CreateStatsCSV(files, path, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, record := range records[1:] {
+		if record[2] != "Bandwidth (B/s)" {
+			continue
+		}
+		// mean of 1000 and 3001 KiB/s
+		want := []string{"TestA", "randread-4k d=32 j=1", "Bandwidth (B/s)", "2", "2048512", "1448878.7652139843", "1024000", "3073024"}
+		for i := range want {
+			if record[i] != want[i] {
+				t.Errorf("expected %v, got %v", want, record[:len(want)])
+				break
+			}
+		}
+		return
+	}
+	t.Errorf("statistics of bandwidth not found in %v", records)
+}
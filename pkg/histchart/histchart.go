@@ -0,0 +1,319 @@
+package histchart
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+
+	bs "github.com/vk-en/fioplot-bs/pkg/bsdata"
+	data "github.com/vk-en/fioplot-bs/pkg/getdata"
+	"github.com/vk-en/fioplot-bs/pkg/units"
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/font"
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/plotutil"
+	"gonum.org/v1/plot/vg"
+)
+
+// bucketsPerDecade - count of histogram buckets for each power of 10 of latency.
+// fio json+ bins are too small for reading, so they are grouped into log buckets.
+const bucketsPerDecade = 20
+
+// latencyType - type of latency which have distribution in fio json+ output
+type latencyType struct {
+	name    string
+	title   string
+	latency func(op *bs.OperationRW) *bs.LatNS
+}
+
+var latencyTypes = []latencyType{
+	{"clat", "Completion latency", func(op *bs.OperationRW) *bs.LatNS { return &op.ClatNS }},
+	{"lat", "Total latency", func(op *bs.OperationRW) *bs.LatNS { return &op.LatNS }},
+}
+
+// testDistribution - latency distribution of one test for pattern
+type testDistribution struct {
+	legend string
+	bins   map[int64]int64 // latency in ns -> count of IOs
+}
+
+// patternDistributions - latency distributions of all tests for one pattern
+type patternDistributions struct {
+	pattern string
+	tests   []*testDistribution
+}
+
+// mergeBins - sum of distributions of several directions
+func mergeBins(all ...map[int64]int64) map[int64]int64 {
+	merged := make(map[int64]int64)
+	for _, bins := range all {
+		for latency, count := range bins {
+			merged[latency] += count
+		}
+	}
+	return merged
+}
+
+// jobDistributions - distributions of job by pattern names according to the key of patterns and the mode for mixed jobs
+func jobDistributions(job *bs.Jobs, global *bs.GlobalOptions, lType latencyType,
+	opts data.Options, keys map[string]bs.PatternKey) map[string]map[int64]int64 {
+	distributions := make(map[string]map[int64]int64)
+	options := job.EffectiveOptions(global)
+	directions := bs.JobDirections(options.RW)
+	fields := data.PatternFields{
+		JobName:  job.TestName,
+		RW:       options.RW,
+		BS:       options.BS,
+		IODepth:  options.IODepth,
+		NumJobs:  options.NumJobs,
+		IOEngine: options.Ioengine,
+	}
+	pattern := func(direction string) string {
+		name := opts.PatternName(fields, direction)
+		key := options.PatternKey()
+		key.Direction = direction
+		keys[name] = key
+		return name
+	}
+
+	if len(directions) == 1 {
+		distributions[pattern("")] = lType.latency(job.Operation(directions[0])).LatencyBins()
+		return distributions
+	}
+
+	var all []map[int64]int64
+	for _, d := range directions {
+		bins := lType.latency(job.Operation(d)).LatencyBins()
+		all = append(all, bins)
+		if opts.Mixed != data.MixedTotal {
+			distributions[pattern(d.String())] = bins
+		}
+	}
+	if opts.Mixed != data.MixedSplit {
+		distributions[pattern(data.TotalDirection)] = mergeBins(all...)
+	}
+	return distributions
+}
+
+// getPatternDistributions - distributions for patterns which are in all tests
+// (or in some tests with opts.Partial).
+// Distributions of repetitions of one test are merged
+func getPatternDistributions(tests []bs.TestInfo, lType latencyType, opts data.Options) []*patternDistributions {
+	var table []*patternDistributions
+	var legends []string
+	keys := make(map[string]bs.PatternKey)
+	allTests := make([]map[string]map[int64]int64, 0, len(tests))
+	for _, test := range tests {
+		testBins := make(map[string]map[int64]int64)
+		for i := range test.JSONResults.Jobs {
+			for pattern, bins := range jobDistributions(&test.JSONResults.Jobs[i],
+				&test.JSONResults.GlobalOptions, lType, opts, keys) {
+				if len(bins) != 0 {
+					testBins[pattern] = bins
+				}
+			}
+		}
+		allTests = append(allTests, testBins)
+		legends = append(legends, opts.GroupName(test.TestName))
+	}
+	if len(allTests) == 0 {
+		return table
+	}
+
+	// patterns which are in all tests or with opts.Partial in any test
+	count := make(map[string]int)
+	for _, testBins := range allTests {
+		for pattern := range testBins {
+			count[pattern]++
+		}
+	}
+	var patterns []string
+	for pattern, tests := range count {
+		if tests == len(allTests) || opts.Partial {
+			patterns = append(patterns, pattern)
+		}
+	}
+	sort.Slice(patterns, func(i, j int) bool {
+		if result := bs.ComparePatterns(keys[patterns[i]], keys[patterns[j]], opts.SortOrder); result != 0 {
+			return result < 0
+		}
+		return patterns[i] < patterns[j]
+	})
+
+	for _, pattern := range patterns {
+		pDistributions := patternDistributions{pattern: pattern}
+		groups := make(map[string]*testDistribution)
+		for index, legend := range legends {
+			if _, ok := allTests[index][pattern]; !ok {
+				continue
+			}
+			if group, ok := groups[legend]; ok {
+				group.bins = mergeBins(group.bins, allTests[index][pattern])
+				continue
+			}
+			groups[legend] = &testDistribution{
+				legend: legend,
+				bins:   allTests[index][pattern],
+			}
+			pDistributions.tests = append(pDistributions.tests, groups[legend])
+		}
+		table = append(table, &pDistributions)
+	}
+	return table
+}
+
+// histogramPoints - share of IOs (%) in log buckets, latency in nanoseconds
+func histogramPoints(bins map[int64]int64) plotter.XYs {
+	var total int64
+	buckets := make(map[int]int64)
+	minBucket, maxBucket := math.MaxInt32, math.MinInt32
+	for latency, count := range bins {
+		bucket := int(math.Floor(math.Log10(float64(latency)) * bucketsPerDecade))
+		buckets[bucket] += count
+		total += count
+		if bucket < minBucket {
+			minBucket = bucket
+		}
+		if bucket > maxBucket {
+			maxBucket = bucket
+		}
+	}
+
+	var points plotter.XYs
+	for bucket := minBucket; bucket <= maxBucket; bucket++ {
+		center := math.Pow(10, (float64(bucket)+0.5)/bucketsPerDecade)
+		points = append(points, plotter.XY{
+			X: center,
+			Y: float64(buckets[bucket]) / float64(total) * 100,
+		})
+	}
+	return points
+}
+
+// cdfPoints - cumulative share of IOs (%) which were completed less than latency in nanoseconds
+func cdfPoints(bins map[int64]int64) plotter.XYs {
+	var total, cumulative int64
+	latencies := make([]int64, 0, len(bins))
+	for latency, count := range bins {
+		latencies = append(latencies, latency)
+		total += count
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+
+	points := make(plotter.XYs, 0, len(latencies))
+	for _, latency := range latencies {
+		cumulative += bins[latency]
+		points = append(points, plotter.XY{
+			X: float64(latency),
+			Y: float64(cumulative) / float64(total) * 100,
+		})
+	}
+	return points
+}
+
+// plotCreate - сreates a skeleton for plotting distributions
+func plotCreate(title, xName, yName, description string) *plot.Plot {
+	p := plot.New()
+	p.Title.Text = title
+	p.Title.TextStyle.Font.Size = font.Length(20)
+	p.Title.Padding = 20
+	p.Y.Label.Text = yName
+	p.Y.Label.Padding = 10
+	p.X.Label.Text = fmt.Sprintf("%s\n\n%s", xName, description)
+	p.X.Label.Padding = 10
+	p.X.Scale = plot.LogScale{}
+	p.X.Tick.Marker = plot.LogTicks{Prec: -1}
+	p.Legend.Top = true
+	p.Legend.Padding = 2
+	p.Add(plotter.NewGrid())
+	return p
+}
+
+// createDistributionChart - draw one line for each test, unit of latency is chosen by range of latencies of all tests
+func createDistributionChart(pDistributions *patternDistributions, system units.System, title, yName, description,
+	filePath string, points func(bins map[int64]int64) plotter.XYs) error {
+	var lines []plotter.XYs
+	var latencies []float64
+	for _, test := range pDistributions.tests {
+		testPoints := points(test.bins)
+		for _, point := range testPoints {
+			latencies = append(latencies, point.X)
+		}
+		lines = append(lines, testPoints)
+	}
+	unit := system.Choose(units.Latency, latencies...)
+
+	p := plotCreate(title, unit.Label("Latency"), yName, description)
+	for index, test := range pDistributions.tests {
+		for i := range lines[index] {
+			lines[index][i].X = unit.Value(lines[index][i].X)
+		}
+		line, err := plotter.NewLine(lines[index])
+		if err != nil {
+			return fmt.Errorf("could not create line for test [%s]: %w", test.legend, err)
+		}
+		line.Color = plotutil.Color(index)
+		line.Width = vg.Points(1.5)
+		p.Add(line)
+		p.Legend.Add(test.legend, line)
+	}
+
+	if err := p.Save(10*vg.Inch, 7*vg.Inch, filePath); err != nil {
+		return fmt.Errorf("could not save chart [%s]: %w", filePath, err)
+	}
+	return nil
+}
+
+// createDir - create directory if it doesn't exist
+func createDir(dirPath string) error {
+	if _, err := os.Stat(dirPath); os.IsNotExist(err) {
+		if err := os.MkdirAll(dirPath, 0755); err != nil {
+			return fmt.Errorf("could not create dir [%s]: %w", dirPath, err)
+		}
+	}
+	return nil
+}
+
+// CreateLatencyCharts - generate latency histograms and CDFs for common patterns of all tests.
+// Distributions are available only in results from fio with --output-format=json+
+func CreateLatencyCharts(allResults bs.AllTestInfo, opts data.Options) error {
+	mainDir := filepath.Join(allResults.MainPathToResults, "latency-histograms")
+	found := false
+
+	for _, lType := range latencyTypes {
+		table := getPatternDistributions(allResults.Tests, lType, opts)
+		if len(table) == 0 {
+			continue
+		}
+		found = true
+
+		histDir := filepath.Join(mainDir, fmt.Sprintf("%s-histogram", lType.name))
+		cdfDir := filepath.Join(mainDir, fmt.Sprintf("%s-cdf", lType.name))
+		for _, dir := range []string{histDir, cdfDir} {
+			if err := createDir(dir); err != nil {
+				return err
+			}
+		}
+
+		for _, pDistributions := range table {
+			fileName := fmt.Sprintf("%s.%s", bs.FileName(pDistributions.pattern), allResults.ImgFormat)
+			if err := createDistributionChart(pDistributions, opts.Units,
+				fmt.Sprintf("%s histogram: %s", lType.title, pDistributions.pattern),
+				"IOs (%)", allResults.Description, filepath.Join(histDir, fileName), histogramPoints); err != nil {
+				return fmt.Errorf("generate histogram for [%s] failed: %w", pDistributions.pattern, err)
+			}
+			if err := createDistributionChart(pDistributions, opts.Units,
+				fmt.Sprintf("%s CDF: %s", lType.title, pDistributions.pattern),
+				"IOs completed (%)", allResults.Description, filepath.Join(cdfDir, fileName), cdfPoints); err != nil {
+				return fmt.Errorf("generate CDF for [%s] failed: %w", pDistributions.pattern, err)
+			}
+		}
+	}
+
+	if !found {
+		return fmt.Errorf("latency bins not found in common patterns, run fio with --output-format=json+")
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package histchart
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+
+	bs "github.com/vk-en/fioplot-bs/pkg/bsdata"
+	"github.com/vk-en/fioplot-bs/pkg/units"
+	"gonum.org/v1/plot/plotter"
+)
+
+// TestZeroLatencyBin - json+ bin of IOs with latency 0 is valid and is drawn at 1 ns
+func TestZeroLatencyBin(t *testing.T) {
+	lat := bs.LatNS{Bins: map[string]int64{"0": 10, "1000": 30, "2000000": 60}}
+	bins := lat.LatencyBins()
+	if bins[1] != 10 || len(bins) != 3 {
+		t.Fatalf("expected IOs of bin 0 in bin of 1 ns, got %v", bins)
+	}
+
+	for name, points := range map[string]func(bins map[int64]int64) plotter.XYs{
+		"histogram": histogramPoints,
+		"cdf":       cdfPoints,
+	} {
+		xys := points(bins)
+		// 1 ns .. 2 ms is less than 7 decades of buckets
+		if len(xys) == 0 || len(xys) > 7*bucketsPerDecade {
+			t.Fatalf("%s: unexpected count of points %d", name, len(xys))
+		}
+		for _, point := range xys {
+			if point.X <= 0 || math.IsInf(point.X, 0) || math.IsNaN(point.Y) {
+				t.Fatalf("%s: point %v can't be drawn with log scale", name, point)
+			}
+		}
+
+		distributions := &patternDistributions{
+			pattern: "randread-4k d=32 j=1",
+			tests:   []*testDistribution{{legend: "TestA", bins: bins}},
+		}
+		filePath := filepath.Join(t.TempDir(), name+".png")
+		if err := createDistributionChart(distributions, units.System{}, name, "IOs (%)", "", filePath, points); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+	}
+}
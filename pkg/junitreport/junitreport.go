@@ -0,0 +1,119 @@
+package junitreport
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+
+	data "github.com/vk-en/fioplot-bs/pkg/getdata"
+)
+
+// testSuites - root element of JUnit XML report
+type testSuites struct {
+	XMLName  xml.Name    `xml:"testsuites"`
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Skipped  int         `xml:"skipped,attr"`
+	Suites   []testSuite `xml:"testsuite"`
+}
+
+// testSuite - checks of one candidate test against baseline
+type testSuite struct {
+	Name      string     `xml:"name,attr"`
+	Tests     int        `xml:"tests,attr"`
+	Failures  int        `xml:"failures,attr"`
+	Skipped   int        `xml:"skipped,attr"`
+	TestCases []testCase `xml:"testcase"`
+}
+
+// testCase - check of one pattern and metric
+type testCase struct {
+	ClassName string   `xml:"classname,attr"`
+	Name      string   `xml:"name,attr"`
+	Failure   *message `xml:"failure,omitempty"`
+	Skipped   *message `xml:"skipped,omitempty"`
+	SystemOut string   `xml:"system-out,omitempty"`
+}
+
+// message - message of failed or skipped testcase
+type message struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr,omitempty"`
+	Text    string `xml:",chardata"`
+}
+
+// describe - values of baseline and candidate, delta and threshold of check
+func describe(result data.CheckResult) string {
+	return fmt.Sprintf("%s: %s=%s, %s=%s, delta=%s, allowed=%s", result.Metric.YDiscription,
+		result.BaselineTest, result.FormatValue(result.Baseline),
+		result.Test, result.FormatValue(result.Candidate),
+		data.FormatCheckValue(result.Delta, "%+.2f%%"), result.FormatThreshold())
+}
+
+// newTestCase - testcase for result of check, classname is pattern and name is metric
+func newTestCase(result data.CheckResult) testCase {
+	tc := testCase{
+		ClassName: result.Pattern,
+		Name:      result.Metric.FileName,
+	}
+	switch {
+	case result.Failed() && result.Missing():
+		tc.Failure = &message{
+			Message: fmt.Sprintf("value is missing: %s", describe(result)),
+			Type:    "missing",
+			Text:    describe(result),
+		}
+	case result.Failed():
+		tc.Failure = &message{
+			Message: fmt.Sprintf("regression is more than allowed: %s", describe(result)),
+			Type:    "regression",
+			Text:    describe(result),
+		}
+	case result.Status == data.CheckNA:
+		tc.Skipped = &message{Message: fmt.Sprintf("value is missing: %s", describe(result))}
+	default:
+		tc.SystemOut = describe(result)
+	}
+	return tc
+}
+
+// CreateJUnitReport - write results of checks of candidates against baseline as JUnit XML,
+// one testsuite for each candidate and one testcase for each pattern and metric
+func CreateJUnitReport(results []data.CheckResult, filePath string) error {
+	report := testSuites{Name: "fioplot-bs"}
+	suites := make(map[string]int)
+	for _, result := range results {
+		index, ok := suites[result.Test]
+		if !ok {
+			index = len(report.Suites)
+			suites[result.Test] = index
+			report.Suites = append(report.Suites, testSuite{
+				Name: fmt.Sprintf("%s vs %s", result.Test, result.BaselineTest),
+			})
+		}
+		suite := &report.Suites[index]
+		tc := newTestCase(result)
+		suite.TestCases = append(suite.TestCases, tc)
+		suite.Tests++
+		report.Tests++
+		if tc.Failure != nil {
+			suite.Failures++
+			report.Failures++
+		}
+		if tc.Skipped != nil {
+			suite.Skipped++
+			report.Skipped++
+		}
+	}
+
+	content, err := xml.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("could not create JUnit report: %w", err)
+	}
+	content = append([]byte(xml.Header), content...)
+	if err := os.WriteFile(filePath, append(content, '\n'), 0644); err != nil {
+		return fmt.Errorf("could not write JUnit report [%s]: %w", filePath, err)
+	}
+	return nil
+}
@@ -0,0 +1,303 @@
+package kneechart
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	bs "github.com/vk-en/fioplot-bs/pkg/bsdata"
+	csvt "github.com/vk-en/fioplot-bs/pkg/csvtable"
+	data "github.com/vk-en/fioplot-bs/pkg/getdata"
+	"github.com/vk-en/fioplot-bs/pkg/units"
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/font"
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/plotutil"
+	"gonum.org/v1/plot/vg"
+)
+
+// axis - value from results of direction of job for axis of chart
+type axis struct {
+	name     string // part of name of dir with charts
+	title    string
+	quantity units.Quantity
+	value    func(op *bs.OperationRW) float64 // in nanoseconds or bytes per second, NaN if value is missing in results
+}
+
+// throughputs - values for X axis
+var throughputs = []axis{
+	{"IOPS", "IOPS", units.Count, func(op *bs.OperationRW) float64 { return op.Iops }},
+	{"BW", "Bandwidth", units.Bandwidth, func(op *bs.OperationRW) float64 { return float64(op.Bw) * units.KiB }},
+}
+
+// latencies - values for Y axis: mean of total latency and percentiles from options
+func latencies(percentiles csvt.LatencyPercentiles) []axis {
+	if len(percentiles.Latencies) == 0 || len(percentiles.Percentiles) == 0 {
+		percentiles = csvt.DefaultPercentiles
+	}
+	var axes = []axis{
+		{"Latency_mean", "Mean latency", units.Latency, func(op *bs.OperationRW) float64 { return op.LatNS.Mean }},
+	}
+	for _, latency := range percentiles.Latencies {
+		for _, percentile := range percentiles.Percentiles {
+			latency, percentile := latency, percentile
+			axes = append(axes, axis{
+				name:     strings.Replace(csvt.PercentileName(latency, percentile), " ", "_", -1),
+				title:    csvt.PercentileName(latency, percentile),
+				quantity: units.Latency,
+				value: func(op *bs.OperationRW) float64 {
+					latNS, err := op.Latency(latency)
+					if err != nil {
+						return math.NaN()
+					}
+					value, ok := latNS.PercentileValue(percentile)
+					if !ok {
+						return math.NaN()
+					}
+					return float64(value)
+				},
+			})
+		}
+	}
+	return axes
+}
+
+// testCurve - results of test for each iodepth, repetitions of test have several results
+type testCurve struct {
+	legend string
+	depths map[int64][]*bs.OperationRW
+}
+
+// family - patterns with the same rw, block size, numjobs and direction, they differ only by iodepth
+type family struct {
+	name  string // Ex. "randread-4k j=1"
+	key   bs.PatternKey
+	tests []*testCurve
+}
+
+// curve - curve of test, it is created on first result of test
+func (f *family) curve(legend string) *testCurve {
+	for _, test := range f.tests {
+		if test.legend == legend {
+			return test
+		}
+	}
+	test := &testCurve{legend: legend, depths: make(map[int64][]*bs.OperationRW)}
+	f.tests = append(f.tests, test)
+	return test
+}
+
+// commonDepths - iodepths which are in all tests (or in any test with partial)
+func (f *family) commonDepths(legends int, partial bool) []int64 {
+	count := make(map[int64]int)
+	for _, test := range f.tests {
+		for depth := range test.depths {
+			count[depth]++
+		}
+	}
+	var depths []int64
+	for depth, tests := range count {
+		if tests == legends || partial {
+			depths = append(depths, depth)
+		}
+	}
+	sort.Slice(depths, func(i, j int) bool { return depths[i] < depths[j] })
+	return depths
+}
+
+// getFamilies - families of patterns with at least two iodepths.
+// Jobs with mixed directions have separate family for each direction
+func getFamilies(tests []bs.TestInfo, opts data.Options) []*family {
+	var families []*family
+	byName := make(map[string]*family)
+	legends := make(map[string]bool)
+	for _, test := range tests {
+		legend := opts.GroupName(test.TestName)
+		legends[legend] = true
+		for i := range test.JSONResults.Jobs {
+			job := &test.JSONResults.Jobs[i]
+			options := job.EffectiveOptions(&test.JSONResults.GlobalOptions)
+			depth, err := strconv.ParseInt(options.IODepth, 10, 64)
+			if err != nil {
+				continue
+			}
+			directions := bs.JobDirections(options.RW)
+			for _, d := range directions {
+				key := options.PatternKey()
+				if len(directions) > 1 {
+					key.Direction = d.String()
+				}
+				name, _ := data.ScalingName(key, data.ScalingIODepth)
+				f, ok := byName[name]
+				if !ok {
+					f = &family{name: name, key: key}
+					byName[name] = f
+					families = append(families, f)
+				}
+				curve := f.curve(legend)
+				curve.depths[depth] = append(curve.depths[depth], job.Operation(d))
+			}
+		}
+	}
+
+	var result []*family
+	for _, f := range families {
+		depths := f.commonDepths(len(legends), opts.Partial)
+		if len(depths) < 2 {
+			continue
+		}
+		common := make(map[int64]bool)
+		for _, depth := range depths {
+			common[depth] = true
+		}
+		for _, test := range f.tests {
+			for depth := range test.depths {
+				if !common[depth] {
+					delete(test.depths, depth)
+				}
+			}
+		}
+		result = append(result, f)
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return bs.ComparePatterns(result[i].key, result[j].key, opts.SortOrder) < 0
+	})
+	return result
+}
+
+// mean - mean of value over results of repetitions, NaN values are skipped
+func mean(results []*bs.OperationRW, value func(op *bs.OperationRW) float64) float64 {
+	var values []float64
+	for _, op := range results {
+		values = append(values, value(op))
+	}
+	return data.CalcStats(values).Mean
+}
+
+// curvePoints - points of test in order of iodepth with labels of iodepth
+func curvePoints(test *testCurve, x, y axis) plotter.XYLabels {
+	var depths []int64
+	for depth := range test.depths {
+		depths = append(depths, depth)
+	}
+	sort.Slice(depths, func(i, j int) bool { return depths[i] < depths[j] })
+
+	var points plotter.XYLabels
+	for _, depth := range depths {
+		point := plotter.XY{X: mean(test.depths[depth], x.value), Y: mean(test.depths[depth], y.value)}
+		if math.IsNaN(point.X) || math.IsNaN(point.Y) {
+			continue
+		}
+		points.XYs = append(points.XYs, point)
+		points.Labels = append(points.Labels, fmt.Sprintf("d=%d", depth))
+	}
+	return points
+}
+
+// plotCreate - сreates a skeleton for plotting latency by throughput
+func plotCreate(title, xName, yName, description string) *plot.Plot {
+	p := plot.New()
+	p.Title.Text = title
+	p.Title.TextStyle.Font.Size = font.Length(20)
+	p.Title.Padding = 20
+	p.Y.Label.Text = yName
+	p.Y.Label.Padding = 10
+	p.Y.Min = 0
+	p.X.Label.Text = fmt.Sprintf("%s\n\n%s", xName, description)
+	p.X.Label.Padding = 10
+	p.X.Min = 0
+	p.Legend.Top = true
+	p.Legend.Left = true
+	p.Legend.Padding = 2
+	p.Add(plotter.NewGrid())
+	return p
+}
+
+// createKneeChart - draw one curve for each test, points of curve are iodepths.
+// Units of axes are chosen by range of values of all curves
+func createKneeChart(f *family, x, y axis, system units.System, description, filePath string) error {
+	var curves []plotter.XYLabels
+	var xValues, yValues []float64
+	for _, test := range f.tests {
+		points := curvePoints(test, x, y)
+		for _, point := range points.XYs {
+			xValues = append(xValues, point.X)
+			yValues = append(yValues, point.Y)
+		}
+		curves = append(curves, points)
+	}
+	xUnit := system.Choose(x.quantity, xValues...)
+	yUnit := system.Choose(y.quantity, yValues...)
+
+	p := plotCreate(fmt.Sprintf("%s: %s by %s", f.name, y.title, x.title),
+		xUnit.Label(x.title), yUnit.Label(y.title), description)
+	found := false
+	for index, test := range f.tests {
+		points := curves[index]
+		if len(points.XYs) == 0 {
+			continue
+		}
+		found = true
+		for i := range points.XYs {
+			points.XYs[i] = plotter.XY{X: xUnit.Value(points.XYs[i].X), Y: yUnit.Value(points.XYs[i].Y)}
+		}
+		line, marks, err := plotter.NewLinePoints(points.XYs)
+		if err != nil {
+			return fmt.Errorf("could not create curve for test [%s]: %w", test.legend, err)
+		}
+		line.Color = plotutil.Color(index)
+		line.Width = vg.Points(1.5)
+		marks.Color = plotutil.Color(index)
+		marks.Shape = plotutil.Shape(index)
+		labels, err := plotter.NewLabels(points)
+		if err != nil {
+			return fmt.Errorf("could not create labels for test [%s]: %w", test.legend, err)
+		}
+		for i := range labels.TextStyle {
+			labels.TextStyle[i].Font.Size = font.Length(8)
+			labels.TextStyle[i].Color = plotutil.Color(index)
+		}
+		labels.Offset = vg.Point{X: vg.Points(4), Y: vg.Points(4)}
+		p.Add(line, marks, labels)
+		p.Legend.Add(test.legend, line, marks)
+	}
+	if !found {
+		return nil
+	}
+
+	if err := p.Save(10*vg.Inch, 7*vg.Inch, filePath); err != nil {
+		return fmt.Errorf("could not save chart [%s]: %w", filePath, err)
+	}
+	return nil
+}
+
+// CreateKneeCharts - generate charts of latency by throughput ("hockey stick") for families
+// of patterns with the same rw, block size and numjobs, points of curves are iodepths.
+// Charts are in knee-charts/<IOPS|BW>-<latency>/<rw-bs j=numjobs>.<imgType>
+func CreateKneeCharts(allResults bs.AllTestInfo, opts data.Options) error {
+	families := getFamilies(allResults.Tests, opts)
+	if len(families) == 0 {
+		return fmt.Errorf("there are no patterns with the same rw, block size and numjobs and different iodepth")
+	}
+
+	mainDir := filepath.Join(allResults.MainPathToResults, "knee-charts")
+	for _, x := range throughputs {
+		for _, y := range latencies(opts.Percentiles) {
+			dirPath := filepath.Join(mainDir, fmt.Sprintf("%s-%s", x.name, y.name))
+			if err := os.MkdirAll(dirPath, 0755); err != nil {
+				return fmt.Errorf("could not create dir for knee charts: %w", err)
+			}
+			for _, f := range families {
+				filePath := filepath.Join(dirPath, fmt.Sprintf("%s.%s", bs.FileName(f.name), allResults.ImgFormat))
+				if err := createKneeChart(f, x, y, opts.Units, allResults.Description, filePath); err != nil {
+					return fmt.Errorf("generate knee chart for [%s] failed: %w", f.name, err)
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package kneechart
+
+import (
+	"reflect"
+	"testing"
+
+	bs "github.com/vk-en/fioplot-bs/pkg/bsdata"
+	data "github.com/vk-en/fioplot-bs/pkg/getdata"
+	"gonum.org/v1/plot/plotter"
+)
+
+// kneeJob - job of 4k blocks with IOPS and mean latency of its directions
+func kneeJob(rw, depth, numjobs string, iops, latency float64) bs.Jobs {
+	job := bs.Jobs{TestName: rw, TestOption: bs.JobOptions{RW: rw, BS: "4k", IODepth: depth, NumJobs: numjobs}}
+	for _, d := range bs.JobDirections(rw) {
+		job.Operation(d).Iops = iops
+		job.Operation(d).LatNS.Mean = latency
+	}
+	return job
+}
+
+// kneeTest - test with jobs
+func kneeTest(name string, jobs ...bs.Jobs) bs.TestInfo {
+	return bs.TestInfo{TestName: name, JSONResults: bs.FioJSON{Jobs: jobs}}
+}
+
+func TestGetFamilies(t *testing.T) {
+	tests := []bs.TestInfo{
+		kneeTest("TestA",
+			kneeJob("randread", "32", "1", 3000, 300),
+			kneeJob("randread", "1", "1", 1000, 100),
+			kneeJob("randread", "8", "1", 2000, 200),
+			kneeJob("randread", "1", "4", 4000, 100),
+			kneeJob("randrw", "1", "1", 100, 10),
+			kneeJob("randrw", "4", "1", 200, 20)),
+		kneeTest("TestB",
+			kneeJob("randread", "8", "1", 2100, 210),
+			kneeJob("randread", "1", "1", 1100, 110),
+			kneeJob("randrw", "1", "1", 100, 10),
+			kneeJob("randrw", "4", "1", 200, 20)),
+	}
+	// numjobs 4 has only one iodepth, iodepth 32 is only in TestA
+	var cases = []struct {
+		partial  bool
+		families []string
+		points   map[string]plotter.XYLabels // points of mean latency by IOPS of the first family
+	}{
+		{false, []string{"randread-4k j=1", "randrw-4k j=1 read", "randrw-4k j=1 write"},
+			map[string]plotter.XYLabels{
+				"TestA": {XYs: plotter.XYs{{X: 1000, Y: 100}, {X: 2000, Y: 200}}, Labels: []string{"d=1", "d=8"}},
+				"TestB": {XYs: plotter.XYs{{X: 1100, Y: 110}, {X: 2100, Y: 210}}, Labels: []string{"d=1", "d=8"}},
+			}},
+		{true, []string{"randread-4k j=1", "randrw-4k j=1 read", "randrw-4k j=1 write"},
+			map[string]plotter.XYLabels{
+				"TestA": {XYs: plotter.XYs{{X: 1000, Y: 100}, {X: 2000, Y: 200}, {X: 3000, Y: 300}},
+					Labels: []string{"d=1", "d=8", "d=32"}},
+				"TestB": {XYs: plotter.XYs{{X: 1100, Y: 110}, {X: 2100, Y: 210}}, Labels: []string{"d=1", "d=8"}},
+			}},
+	}
+	for _, c := range cases {
+		families := getFamilies(tests, data.Options{Partial: c.partial})
+		var names []string
+		for _, f := range families {
+			names = append(names, f.name)
+		}
+		if !reflect.DeepEqual(names, c.families) {
+			t.Errorf("partial=%v: expected families %v, got %v", c.partial, c.families, names)
+			continue
+		}
+		for _, curve := range families[0].tests {
+			points := curvePoints(curve, throughputs[0], latencies(data.Options{}.Percentiles)[0])
+			if !reflect.DeepEqual(points, c.points[curve.legend]) {
+				t.Errorf("partial=%v: expected points %v of %s, got %v", c.partial, c.points[curve.legend], curve.legend, points)
+			}
+		}
+	}
+}
+
+// TestCurvePointsMissing - points without value of axis are skipped
+func TestCurvePointsMissing(t *testing.T) {
+	f := getFamilies([]bs.TestInfo{kneeTest("TestA",
+		kneeJob("randread", "1", "1", 1000, 100),
+		kneeJob("randread", "8", "1", 2000, 200))}, data.Options{})[0]
+	f.tests[0].depths[8][0].ClatNS.Percentile = map[string]int64{"99.000000": 500}
+	// p99 of completion latency is only in results of iodepth 8
+	points := curvePoints(f.tests[0], throughputs[0], latencies(data.Options{}.Percentiles)[1])
+	want := plotter.XYLabels{XYs: plotter.XYs{{X: 2000, Y: 500}}, Labels: []string{"d=8"}}
+	if !reflect.DeepEqual(points, want) {
+		t.Errorf("expected points %v, got %v", want, points)
+	}
+}
+
+// TestCreateKneeChartsWithoutFamilies - error if no pattern has two iodepths
+func TestCreateKneeChartsWithoutFamilies(t *testing.T) {
+	results := bs.AllTestInfo{
+		Tests: []bs.TestInfo{kneeTest("TestA",
+			kneeJob("randread", "1", "1", 1000, 100),
+			kneeJob("randwrite", "8", "1", 2000, 200))},
+		MainPathToResults: t.TempDir(),
+		ImgFormat:         "png",
+	}
+	if err := CreateKneeCharts(results, data.Options{}); err == nil {
+		t.Error("expected error without patterns with several iodepths")
+	}
+}
@@ -0,0 +1,161 @@
+package loggraphs
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	bs "github.com/vk-en/fioplot-bs/pkg/bsdata"
+	"github.com/wcharczuk/go-chart/v2"
+)
+
+// logTitles - names of log types in headers of charts
+var logTitles = map[bs.LogFileType]string{
+	bs.LOG_TYPE_BW:   "Bandwidth",
+	bs.LOG_TYPE_IOPS: "IOPS",
+	bs.LOG_TYPE_LAT:  "Total latency",
+	bs.LOG_TYPE_CLAT: "Completion latency",
+	bs.LOG_TYPE_SLAT: "Submission latency",
+}
+
+// testLog - glued log file of one test
+type testLog struct {
+	testName string
+	info     bs.LogFileInfo
+	data     LogFile
+}
+
+// overlayKey - logs of jobs with the same rw, block size, iodepth and numjobs in different tests
+// are drawn on one chart, names of jobs and of their log files may differ between tests
+type overlayKey struct {
+	pattern bs.PatternKey
+	logType bs.LogFileType
+}
+
+// newOverlayKey - key of glued log file of job of test
+func newOverlayKey(testInfo bs.TestInfo, info bs.LogFileInfo) overlayKey {
+	options := info.InfoJobs.EffectiveOptions(&testInfo.JSONResults.GlobalOptions)
+	return overlayKey{pattern: options.PatternKey(), logType: info.FileType}
+}
+
+// String - name of overlay chart by log type and pattern (Ex. "clat-randread-4k d=32 j=1")
+func (k overlayKey) String() string {
+	return fmt.Sprintf("%s-%s-%s d=%s j=%s", overlayPrefixes[k.logType],
+		k.pattern.RW, k.pattern.BS, k.pattern.IODepth, k.pattern.NumJobs)
+}
+
+// overlayPrefixes - prefixes of names of overlay charts by log type
+var overlayPrefixes = map[bs.LogFileType]string{
+	bs.LOG_TYPE_BW:        "bw",
+	bs.LOG_TYPE_IOPS:      "iops",
+	bs.LOG_TYPE_LAT:       "lat",
+	bs.LOG_TYPE_CLAT:      "clat",
+	bs.LOG_TYPE_SLAT:      "slat",
+	bs.LOG_TYPE_CLAT_HIST: "clat_hist",
+}
+
+// overlayLogs - glued log files of tests by pattern of job and log type
+type overlayLogs struct {
+	keys  []overlayKey // in order of the first test with log file
+	tests map[overlayKey][]*testLog
+}
+
+// newOverlayLogs - empty set of glued log files of tests
+func newOverlayLogs() *overlayLogs {
+	return &overlayLogs{tests: make(map[overlayKey][]*testLog)}
+}
+
+// add - add glued log file of test. If several jobs of test have the same pattern,
+// only the log of the first of them is drawn
+func (o *overlayLogs) add(key overlayKey, testName string, info bs.LogFileInfo, data LogFile) {
+	if _, ok := o.tests[key]; !ok {
+		o.keys = append(o.keys, key)
+	}
+	for _, log := range o.tests[key] {
+		if log.testName == testName {
+			return
+		}
+	}
+	o.tests[key] = append(o.tests[key], &testLog{testName: testName, info: info, data: data})
+}
+
+// overlayInfo - information for chart with logs of several tests, statistics and
+// parameters of fio differ between tests, so only the job of the first test is described
+func overlayInfo(logs []*testLog) bs.LogFileInfo {
+	var names []string
+	for _, log := range logs {
+		names = append(names, log.testName)
+	}
+	info := logs[0].info
+	info.Header = fmt.Sprintf("%s for %s  [tests: %s]",
+		logTitles[info.FileType], info.InfoJobs.TestName, strings.Join(names, ", "))
+	info.BasicInfoStr = fmt.Sprintf("Logs of %d tests: %s", len(logs), strings.Join(names, ", "))
+	info.InfoAboutFio = ""
+	return info
+}
+
+// createOverlayGraph - draw one line for each test on common time line,
+// jobs with mixed directions have a chart for each direction
+func createOverlayGraph(key overlayKey, logs []*testLog, dirForGraphs, imgFormat string) error {
+	info := overlayInfo(logs)
+	info.ImgName = bs.FileName(key.String())
+	info.DirForImage = getFinishDirForGraph(dirForGraphs, info.FileType)
+
+	var all LogFile
+	for _, log := range logs {
+		all = append(all, log.data...)
+	}
+	directions := all.directions()
+	for _, direction := range directions {
+		dirInfo := info
+		if len(directions) > 1 {
+			// statistics of tests are not shown on overlay chart
+			dirInfo = directionInfo(info, direction)
+			dirInfo.BasicInfoStr = info.BasicInfoStr
+		}
+
+		var series []chart.Series
+		for _, log := range logs {
+			xVal, yVal := getPoints(log.data.direction(direction), info.FileType)
+			if len(xVal) == 0 {
+				continue
+			}
+			series = append(series, chart.ContinuousSeries{
+				Name:    log.testName,
+				XValues: xVal,
+				YValues: yVal,
+			})
+		}
+		if len(series) < 2 {
+			continue
+		}
+
+		graph := newLogChart(dirInfo, series)
+		if err := saveLogChart(graph, dirInfo, imgFormat); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// createOverlayGraphs - charts with logs of jobs with the same pattern from several tests.
+// Charts are in log-graphs/compare/<log type>/<log type>-<pattern>.<imgFormat>
+func createOverlayGraphs(overlays *overlayLogs, mainDir, imgFormat string) error {
+	dirForGraphs := filepath.Join(mainDir, "compare")
+	for _, key := range overlays.keys {
+		logs := overlays.tests[key]
+		if len(logs) < 2 {
+			continue
+		}
+		if _, err := os.Stat(dirForGraphs); os.IsNotExist(err) {
+			if err := os.Mkdir(dirForGraphs, 0755); err != nil {
+				return fmt.Errorf("could not create dir for overlay log graphs: %w", err)
+			}
+		}
+		if err := createOverlayGraph(key, logs, dirForGraphs, imgFormat); err != nil {
+			return fmt.Errorf("could not create overlay log graph for %s: %w", key, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package loggraphs
+
+import (
+	"testing"
+
+	bs "github.com/vk-en/fioplot-bs/pkg/bsdata"
+)
+
+// testLogInfo - test with one job and information about its glued log
+func testLogInfo(testName, jobName, bsize string, logType bs.LogFileType) (bs.TestInfo, bs.LogFileInfo) {
+	test := bs.TestInfo{TestName: testName}
+	test.JSONResults.GlobalOptions = bs.GlobalOptions{RW: "randread", IODepth: "32"}
+	test.JSONResults.Jobs = []bs.Jobs{{TestName: jobName, TestOption: bs.JobOptions{BS: bsize, NumJobs: "1"}}}
+	return test, bs.LogFileInfo{FileType: logType, InfoJobs: &test.JSONResults.Jobs[0]}
+}
+
+// TestOverlayKeys - logs of jobs are matched between tests by pattern and log type, not by names of jobs
+func TestOverlayKeys(t *testing.T) {
+	overlays := newOverlayLogs()
+	for _, log := range []struct {
+		test, job, bs string
+		logType       bs.LogFileType
+	}{
+		{"TestA", "randread-4k-32", "4k", bs.LOG_TYPE_CLAT},
+		{"TestA", "randread-4k-32", "4k", bs.LOG_TYPE_BW},
+		{"TestA", "randread-64k-32", "64k", bs.LOG_TYPE_CLAT},
+		{"TestB", "job1", "4k", bs.LOG_TYPE_CLAT},
+		{"TestB", "job2", "4k", bs.LOG_TYPE_CLAT}, // second job of test with the same pattern
+	} {
+		test, info := testLogInfo(log.test, log.job, log.bs, log.logType)
+		overlays.add(newOverlayKey(test, info), log.test, info, nil)
+	}
+
+	if len(overlays.keys) != 3 {
+		t.Fatalf("expected 3 overlay charts, got %v", overlays.keys)
+	}
+	key := overlays.keys[0]
+	if name := key.String(); name != "clat-randread-4k d=32 j=1" {
+		t.Errorf("unexpected name of overlay chart %q", name)
+	}
+	logs := overlays.tests[key]
+	if len(logs) != 2 || logs[0].testName != "TestA" || logs[1].testName != "TestB" || logs[1].info.InfoJobs.TestName != "job1" {
+		t.Errorf("expected logs of TestA and of the first job of TestB on one chart, got %d logs", len(logs))
+	}
+}
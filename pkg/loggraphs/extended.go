@@ -0,0 +1,313 @@
+package loggraphs
+
+import (
+	"fmt"
+	"math/rand"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	bs "github.com/vk-en/fioplot-bs/pkg/bsdata"
+	"github.com/vk-en/fioplot-bs/pkg/units"
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/plotutil"
+	"gonum.org/v1/plot/vg"
+	"gonum.org/v1/plot/vg/draw"
+)
+
+// maxOffsetPoints - IOs on chart of offsets, the other IOs are skipped by reservoir sampling
+// to keep memory and size of chart bounded for logs with millions of IOs
+const maxOffsetPoints = 50000
+
+// prioClassMinor - minor version of fio 3 which writes class and level of priority of IO
+// (class << 13 | level) in logs, older versions write only 1 for IOs with high priority and 0 for the others
+const prioClassMinor = 30
+
+// reFioVersion - major and minor version of fio (Ex. fio-3.28, fio-3.35-10-g1234abc)
+var reFioVersion = regexp.MustCompile(`^fio-(\d+)\.(\d+)`)
+
+// extendedLogTypes - latency logs which are used for charts of extended columns of job in order of preference
+var extendedLogTypes = []struct {
+	suffix string
+	fType  bs.LogFileType
+}{
+	{"_clat", bs.LOG_TYPE_CLAT},
+	{"_lat", bs.LOG_TYPE_LAT},
+	{"_slat", bs.LOG_TYPE_SLAT},
+}
+
+// offsetPoint - offset of IO in time
+type offsetPoint struct {
+	seconds   float64
+	offset    float64 // bytes
+	direction int
+}
+
+// extendedStats - block size, offset and priority of each IO from latency logs of job
+type extendedStats struct {
+	hasOffset  bool
+	legacyPrio bool // priority of IO is 0 or 1 in logs of old versions of fio
+	offsets    []offsetPoint
+	seen       int64
+	random     *rand.Rand
+	bySize     map[int]map[int]*latencyHistogram // direction -> block size -> latencies
+	byPrio     map[int]map[int]*latencyHistogram // direction -> priority -> latencies
+}
+
+// newExtendedStats - empty statistics, offsets are collected only for logs with log_offset=1,
+// priorities are named by version of fio which wrote logs
+func newExtendedStats(hasOffset bool, fioVersion string) *extendedStats {
+	return &extendedStats{
+		hasOffset:  hasOffset,
+		legacyPrio: legacyPrio(fioVersion),
+		// the same IOs are on chart for the same logs
+		random: rand.New(rand.NewSource(1)),
+		bySize: make(map[int]map[int]*latencyHistogram),
+		byPrio: make(map[int]map[int]*latencyHistogram),
+	}
+}
+
+// addLatency - add latency of IO to histogram of direction and key
+func addLatency(groups map[int]map[int]*latencyHistogram, direction, key, value int) {
+	if _, ok := groups[direction]; !ok {
+		groups[direction] = make(map[int]*latencyHistogram)
+	}
+	hist, ok := groups[direction][key]
+	if !ok {
+		hist = &latencyHistogram{counts: make(map[int]uint64)}
+		groups[direction][key] = hist
+	}
+	hist.add(value, 1)
+}
+
+// add - add IO from line of latency log
+func (e *extendedStats) add(line LogLine) {
+	if line.bs > 0 {
+		addLatency(e.bySize, line.opType, line.bs, line.value)
+	}
+	addLatency(e.byPrio, line.opType, line.prio, line.value)
+
+	if !e.hasOffset {
+		return
+	}
+	point := offsetPoint{
+		seconds:   float64(line.time) / 1000,
+		offset:    float64(line.offset),
+		direction: line.opType,
+	}
+	e.seen++
+	if len(e.offsets) < maxOffsetPoints {
+		e.offsets = append(e.offsets, point)
+	} else if index := e.random.Int63n(e.seen); index < maxOffsetPoints {
+		e.offsets[index] = point
+	}
+}
+
+// extendedLog - true if log is the preferred latency log of job for charts of extended columns,
+// the columns are the same in all latency logs of job, so charts are created only once
+func (t LogGF) extendedLog(patternName string) bool {
+	logType := logTypeByName(patternName)
+	for i, candidate := range extendedLogTypes {
+		if candidate.fType != logType {
+			continue
+		}
+		base := strings.TrimSuffix(patternName, candidate.suffix)
+		for _, preferred := range extendedLogTypes[:i] {
+			for _, group := range t {
+				if group.patternName == base+preferred.suffix {
+					return false
+				}
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// legacyPrio - true if fio of version writes only 0 and 1 as priority of IO in logs,
+// unknown versions are treated as new ones
+func legacyPrio(fioVersion string) bool {
+	match := reFioVersion.FindStringSubmatch(fioVersion)
+	if match == nil {
+		return false
+	}
+	major, _ := strconv.Atoi(match[1])
+	minor, _ := strconv.Atoi(match[2])
+	return major < 3 || (major == 3 && minor < prioClassMinor)
+}
+
+// prioName - class and level of priority of IO (Ex. rt/0), logs of old versions of fio (legacy)
+// have only 1 for IOs with high priority and 0 for the others
+func prioName(prio int, legacy bool) string {
+	if legacy {
+		switch prio {
+		case 0:
+			return "normal"
+		case 1:
+			return "high"
+		}
+	}
+	classes := []string{"none", "rt", "be", "idle"}
+	class := prio >> 13
+	if class < len(classes) {
+		return fmt.Sprintf("%s/%d", classes[class], prio&(1<<13-1))
+	}
+	return fmt.Sprintf("%d", prio)
+}
+
+// createOffsetChart - scatter of offsets of IOs by time to show locality of access
+func createOffsetChart(stats *extendedStats, info bs.LogFileInfo, filePath string) error {
+	var offsets []float64
+	for _, point := range stats.offsets {
+		offsets = append(offsets, point.offset)
+	}
+	unit := info.Units.Choose(units.Size, offsets...)
+	points := make(map[int]plotter.XYs)
+	for _, point := range stats.offsets {
+		points[point.direction] = append(points[point.direction], plotter.XY{X: point.seconds, Y: unit.Value(point.offset)})
+	}
+	var directions []int
+	for direction := range points {
+		directions = append(directions, direction)
+	}
+	sort.Ints(directions)
+
+	p := newLogPlot(info, unit.Label("Offset"))
+	p.Add(plotter.NewGrid())
+	for _, direction := range directions {
+		scatter, err := plotter.NewScatter(points[direction])
+		if err != nil {
+			return fmt.Errorf("could not create scatter of offsets: %w", err)
+		}
+		scatter.GlyphStyle.Color = plotutil.Color(direction)
+		scatter.GlyphStyle.Radius = vg.Points(1.5)
+		scatter.GlyphStyle.Shape = draw.CircleGlyph{}
+		p.Add(scatter)
+		p.Legend.Add(bs.Direction(direction).String(), scatter)
+	}
+	return saveLogPlot(p, filePath)
+}
+
+// latencyKeys - values of key (block size or priority) of IOs of all directions in ascending order
+func latencyKeys(groups map[int]map[int]*latencyHistogram) []int {
+	keySet := make(map[int]bool)
+	for _, histograms := range groups {
+		for key := range histograms {
+			keySet[key] = true
+		}
+	}
+	var keys []int
+	for key := range keySet {
+		keys = append(keys, key)
+	}
+	sort.Ints(keys)
+	return keys
+}
+
+// createLatencyBars - mean and p99 of latencies for each value of key (block size or priority)
+func createLatencyBars(groups map[int]map[int]*latencyHistogram, name func(key int) string,
+	info bs.LogFileInfo, filePath string) error {
+	keys := latencyKeys(groups)
+	var directions []int
+	var largest []float64
+	for direction, histograms := range groups {
+		directions = append(directions, direction)
+		for _, hist := range histograms {
+			largest = append(largest, float64(hist.max))
+		}
+	}
+	sort.Ints(directions)
+	unit := info.Units.Choose(units.Latency, largest...)
+
+	var labels []string
+	for _, key := range keys {
+		labels = append(labels, name(key))
+	}
+
+	p := newLogPlot(info, unit.Label(info.YName))
+	p.Add(plotter.NewGrid())
+	p.NominalX(labels...)
+	width := vg.Points(40)
+	bars := len(directions) * 2
+	index := 0
+	for _, direction := range directions {
+		for _, stat := range []struct {
+			name  string
+			value func(h *latencyHistogram) float64
+		}{
+			{"mean", func(h *latencyHistogram) float64 { return h.sum / float64(h.total) }},
+			{"p99", func(h *latencyHistogram) float64 { return h.percentile(99) }},
+		} {
+			values := make(plotter.Values, len(keys))
+			for i, key := range keys {
+				if hist, ok := groups[direction][key]; ok {
+					values[i] = unit.Value(stat.value(hist))
+				}
+			}
+			bar, err := plotter.NewBarChart(values, width)
+			if err != nil {
+				return fmt.Errorf("could not create bars of latencies: %w", err)
+			}
+			bar.Color = plotutil.Color(index)
+			bar.LineStyle.Width = 0
+			bar.Offset = width * vg.Length(2*index-bars+1) / 2
+			p.Add(bar)
+			p.Legend.Add(fmt.Sprintf("%s %s", bs.Direction(direction), stat.name), bar)
+			index++
+		}
+	}
+	// space for legend above bars and between groups and edges of chart
+	p.Y.Max *= 1.15
+	p.X.Min, p.X.Max = -0.5, float64(len(keys))-0.5
+	return saveLogPlot(p, filePath)
+}
+
+// createExtendedCharts - charts of offsets of IOs by time and of latencies by block size
+// and by priority of IOs from extended columns of latency log of job
+func createExtendedCharts(stats *extendedStats, logInfo bs.LogFileInfo, testName, testDir, imgFormat string) error {
+	jobName := logInfo.InfoJobs.TestName
+	charts := []struct {
+		dir    string
+		header string
+		xName  string
+		draw   bool
+		create func(info bs.LogFileInfo, filePath string) error
+	}{
+		{"offset", fmt.Sprintf("Offsets of IOs for %s  [test: %s]", jobName, testName), logInfo.XName,
+			len(stats.offsets) > 0,
+			func(info bs.LogFileInfo, filePath string) error {
+				return createOffsetChart(stats, info, filePath)
+			}},
+		{"bs", fmt.Sprintf("%s by block size for %s  [test: %s]", logTitles[logInfo.FileType], jobName, testName),
+			"Block size", len(latencyKeys(stats.bySize)) > 1,
+			func(info bs.LogFileInfo, filePath string) error {
+				return createLatencyBars(stats.bySize, func(bytes int) string {
+					return bs.FormatBlockSize(int64(bytes))
+				}, info, filePath)
+			}},
+		{"prio", fmt.Sprintf("%s by priority of IO for %s  [test: %s]", logTitles[logInfo.FileType], jobName, testName),
+			"Priority of IO (class/level)", len(latencyKeys(stats.byPrio)) > 1,
+			func(info bs.LogFileInfo, filePath string) error {
+				return createLatencyBars(stats.byPrio, func(prio int) string {
+					return prioName(prio, stats.legacyPrio)
+				}, info, filePath)
+			}},
+	}
+	for _, chart := range charts {
+		// offsets are in logs only with log_offset=1, charts with only one block size or priority are useless
+		if !chart.draw {
+			continue
+		}
+		info := logInfo
+		info.Header = chart.header
+		info.XName = chart.xName
+		info.ImgName = fmt.Sprintf("%s-%s", chart.dir, logInfo.ImgName)
+		filePath := filepath.Join(makeGraphDir(testDir, chart.dir), fmt.Sprintf("%s.%s", info.ImgName, imgFormat))
+		if err := chart.create(info, filePath); err != nil {
+			return err
+		}
+	}
+	return nil
+}
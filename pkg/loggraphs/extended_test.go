@@ -0,0 +1,47 @@
+package loggraphs
+
+import "testing"
+
+func TestPrioName(t *testing.T) {
+	var tests = []struct {
+		prio   int
+		legacy bool
+		name   string
+	}{
+		{0, true, "normal"},
+		{1, true, "high"},
+		{0, false, "none/0"},
+		{1, false, "none/1"},
+		{1<<13 | 0, false, "rt/0"},
+		{2<<13 | 4, false, "be/4"},
+		{3<<13 | 7, false, "idle/7"},
+		{4 << 13, false, "32768"},
+	}
+	for _, test := range tests {
+		if name := prioName(test.prio, test.legacy); name != test.name {
+			t.Errorf("prioName(%d, %v) = %s, expected %s", test.prio, test.legacy, name, test.name)
+		}
+	}
+}
+
+func TestLegacyPrio(t *testing.T) {
+	var tests = []struct {
+		version string
+		legacy  bool
+	}{
+		{"fio-2.21", true},
+		{"fio-3.1", true},
+		{"fio-3.28", true},
+		{"fio-3.29-7-g1234abc", true},
+		{"fio-3.30", false},
+		{"fio-3.35-10-g1234abc", false},
+		{"fio-4.0", false},
+		{"", false},
+		{"unknown", false},
+	}
+	for _, test := range tests {
+		if legacy := legacyPrio(test.version); legacy != test.legacy {
+			t.Errorf("legacyPrio(%q) = %v, expected %v", test.version, legacy, test.legacy)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package loggraphs
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"path/filepath"
+	"strings"
+
+	bs "github.com/vk-en/fioplot-bs/pkg/bsdata"
+	"github.com/vk-en/fioplot-bs/pkg/units"
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/font"
+	"gonum.org/v1/plot/palette/moreland"
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/vg"
+)
+
+// heatRowsPerOctave - rows of heatmap for each power of 2 of latency
+const heatRowsPerOctave = 4
+
+// heatGrid - counts of IOs by time buckets (columns) and ranges of latency (rows) for plotter.HeatMap.
+// Y of rows is log2 of latency in nanoseconds, so rows have the same height on chart
+type heatGrid struct {
+	seconds []float64
+	rows    []float64
+	counts  [][]float64 // log10 of count of IOs for each column and row, NaN without IOs
+}
+
+func (g *heatGrid) Dims() (int, int)   { return len(g.seconds), len(g.rows) }
+func (g *heatGrid) Z(c, r int) float64 { return g.counts[c][r] }
+func (g *heatGrid) X(c int) float64    { return g.seconds[c] }
+func (g *heatGrid) Y(r int) float64    { return g.rows[r] }
+
+// heatRow - row of heatmap for latency in nanoseconds
+func heatRow(value float64) int {
+	return int(math.Floor(math.Log2(math.Max(value, 1)) * heatRowsPerOctave))
+}
+
+// newHeatGrid - grid of histograms of direction, nil if there are less than two time buckets
+func newHeatGrid(bands *latencyBands, direction bs.Direction) *heatGrid {
+	buckets, seconds := bands.timeline(direction)
+	if len(buckets) < 2 {
+		return nil
+	}
+	histograms := bands.buckets[int(direction)]
+	minRow, maxRow := math.MaxInt32, math.MinInt32
+	for _, bucket := range buckets {
+		for index := range histograms[bucket].counts {
+			row := heatRow(histValue(index))
+			if row < minRow {
+				minRow = row
+			}
+			if row > maxRow {
+				maxRow = row
+			}
+		}
+	}
+	if maxRow == minRow {
+		maxRow++
+	}
+
+	grid := &heatGrid{seconds: seconds}
+	for row := minRow; row <= maxRow; row++ {
+		grid.rows = append(grid.rows, (float64(row)+0.5)/heatRowsPerOctave)
+	}
+	for _, bucket := range buckets {
+		counts := make([]float64, len(grid.rows))
+		for index, count := range histograms[bucket].counts {
+			counts[heatRow(histValue(index))-minRow] += float64(count)
+		}
+		for row, count := range counts {
+			if count == 0 {
+				counts[row] = math.NaN()
+			} else {
+				counts[row] = math.Log10(count)
+			}
+		}
+		grid.counts = append(grid.counts, counts)
+	}
+	return grid
+}
+
+// latencyTicks - ticks of Y axis at 1, 2 and 5 of each power of 10 of latency between rows of grid,
+// unit is chosen for each tick (Ex. 500µs, 1ms) unless it is set by user
+func latencyTicks(grid *heatGrid, system units.System) plot.ConstantTicks {
+	var ticks plot.ConstantTicks
+	low := grid.rows[0] - 0.5/heatRowsPerOctave
+	high := grid.rows[len(grid.rows)-1] + 0.5/heatRowsPerOctave
+	for ns := 1.0; ns <= 1e12; ns *= 10 {
+		for _, value := range []float64{ns, 2 * ns, 5 * ns} {
+			y := math.Log2(value)
+			if y < low || y > high {
+				continue
+			}
+			ticks = append(ticks, plot.Tick{Value: y, Label: system.Choose(units.Latency, value).Format(value)})
+		}
+	}
+	return ticks
+}
+
+// heatColors - colors of heatmap from dark for few IOs to yellow for the most of IOs
+type heatColors []color.Color
+
+func (c heatColors) Colors() []color.Color { return c }
+
+// heatPalette - black body colors without the lightest ones, so cells with many IOs
+// are not confused with empty cells
+func heatPalette(count int) heatColors {
+	colors := moreland.ExtendedBlackBody()
+	colors.SetMin(0)
+	colors.SetMax(1)
+	var palette heatColors
+	for i := 0; i < count; i++ {
+		c, err := colors.At(0.05 + 0.8*float64(i)/float64(count-1))
+		if err != nil {
+			c = color.Black
+		}
+		palette = append(palette, c)
+	}
+	return palette
+}
+
+// newLogPlot - сreates a skeleton for gonum charts of logs with information about job under X axis
+func newLogPlot(info bs.LogFileInfo, yName string) *plot.Plot {
+	p := plot.New()
+	p.Title.Text = info.Header
+	p.Title.TextStyle.Font.Size = font.Length(20)
+	p.Title.Padding = 20
+	p.Y.Label.Text = yName
+	p.Y.Label.Padding = 10
+	p.X.Label.Text = strings.Join([]string{info.XName, "", info.BasicInfoStr, info.TestDescription,
+		info.InfoAboutFio, info.Description}, "\n")
+	p.X.Label.Padding = 10
+	p.Legend.Top = true
+	p.Legend.Padding = 2
+	return p
+}
+
+// saveLogPlot - save gonum chart with the same size in pixels as the other log graphs
+func saveLogPlot(p *plot.Plot, filePath string) error {
+	if err := p.Save(LogChartWidth*vg.Inch/96, LogChartHeight*vg.Inch/96, filePath); err != nil {
+		return fmt.Errorf("could not save chart [%s]: %w", filePath, err)
+	}
+	return nil
+}
+
+// createHeatmap - heatmap of latencies of IOs by time with lines of percentiles
+func createHeatmap(bands *latencyBands, direction bs.Direction, info bs.LogFileInfo, filePath string) error {
+	grid := newHeatGrid(bands, direction)
+	if grid == nil {
+		return nil
+	}
+
+	p := newLogPlot(info, "Latency (color is log10 of count of IOs)")
+	p.Y.Tick.Marker = latencyTicks(grid, info.Units)
+
+	heatmap := plotter.NewHeatMap(grid, heatPalette(255))
+	heatmap.NaN = color.Transparent
+	p.Add(heatmap)
+
+	_, seconds := bands.timeline(direction)
+	for _, band := range latencyBandsLines {
+		band := band
+		values := bands.values(direction, func(h *latencyHistogram) float64 { return h.percentile(band.percentile) })
+		points := make(plotter.XYs, len(values))
+		for i, value := range values {
+			points[i] = plotter.XY{X: seconds[i], Y: math.Log2(math.Max(value, 1))}
+		}
+		line, err := plotter.NewLine(points)
+		if err != nil {
+			return fmt.Errorf("could not create line of %s: %w", band.name, err)
+		}
+		line.Color = band.color
+		line.Width = vg.Points(1.5)
+		p.Add(line)
+		p.Legend.Add(band.name, line)
+	}
+
+	return saveLogPlot(p, filePath)
+}
+
+// createHeatmaps - heatmaps of histogram log of job, a heatmap for each direction of job
+func createHeatmaps(bands *latencyBands, logInfo bs.LogFileInfo, imgFormat string) error {
+	directions := bands.directions()
+	for _, direction := range directions {
+		info := logInfo
+		if len(directions) > 1 {
+			info = directionInfo(logInfo, direction)
+		}
+		filePath := filepath.Join(info.DirForImage, fmt.Sprintf("%s.%s", info.ImgName, imgFormat))
+		if err := createHeatmap(bands, direction, info, filePath); err != nil {
+			return err
+		}
+	}
+	return nil
+}
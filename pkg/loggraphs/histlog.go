@@ -0,0 +1,110 @@
+package loggraphs
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// histLayouts - count of groups of bins in fio histograms (FIO_IO_U_PLAT_GROUP_NR) and nanoseconds
+// in unit of latency: 29 groups of nanoseconds since fio-3.0, 19 groups of microseconds before
+var histLayouts = []struct {
+	groups int
+	unit   int
+}{
+	{29, 1},
+	{19, 1000},
+}
+
+// histLayout - layout of bins in line of histogram log
+type histLayout struct {
+	stride int // bins of fio in one bin of log, 2^log_hist_coarseness
+	unit   int // nanoseconds in unit of latency
+}
+
+// detectHistLayout - layout of bins by count of bins in line of histogram log
+func detectHistLayout(bins int) (histLayout, bool) {
+	for _, layout := range histLayouts {
+		count := layout.groups << histSubBits
+		for coarseness := 0; coarseness <= histSubBits; coarseness++ {
+			if count>>coarseness == bins {
+				return histLayout{stride: 1 << coarseness, unit: layout.unit}, true
+			}
+		}
+	}
+	return histLayout{}, false
+}
+
+// parseHistLine - add counts of bins of line of histogram log into time bucket of its direction.
+// Line is "time, direction, block size, bin 0, bin 1, ..." with counts of IOs completed in interval,
+// newer versions of fio can add priority before bins
+func parseHistLine(text string, bands *latencyBands) error {
+	fields := strings.Split(text, ",")
+	for _, prefix := range []int{3, 4} {
+		if len(fields) <= prefix {
+			break
+		}
+		layout, ok := detectHistLayout(len(fields) - prefix)
+		if !ok {
+			continue
+		}
+		time, err := strconv.Atoi(strings.TrimSpace(fields[0]))
+		if err != nil {
+			return fmt.Errorf("invalid time in line: %q", fields[0])
+		}
+		direction, err := strconv.Atoi(strings.TrimSpace(fields[1]))
+		if err != nil {
+			return fmt.Errorf("invalid direction in line: %q", fields[1])
+		}
+		var hist *latencyHistogram
+		for bin, field := range fields[prefix:] {
+			count, err := strconv.ParseUint(strings.TrimSpace(field), 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid count of IOs in bin %d: %q", bin, field)
+			}
+			if count == 0 {
+				continue
+			}
+			// intervals without IOs are not added
+			if hist == nil {
+				hist = bands.histogram(time, direction)
+			}
+			// coarse bin is represented by the middle bin of fio in it
+			value := histValue(bin*layout.stride+layout.stride/2) * float64(layout.unit)
+			hist.add(int(value), count)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown layout of bins of histogram log with %d fields", len(fields))
+}
+
+// readHistLog - add histograms from histogram log of one thread (write_hist_log) to bands,
+// the file is read line by line
+func readHistLog(filePath string, bands *latencyBands) error {
+	logFile, err := os.Open(filePath)
+	if err != nil {
+		return fmt.Errorf("could not open log file %s err:%w", filePath, err)
+	}
+	defer logFile.Close()
+
+	scanner := bufio.NewScanner(logFile)
+	// line has count of IOs for each of 1856 bins
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	number := 0
+	for scanner.Scan() {
+		number++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		if err := parseHistLine(text, bands); err != nil {
+			return fmt.Errorf("could not read log file %s line %d: %w", filePath, number, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("could not read log file %s err:%w", filePath, err)
+	}
+	return nil
+}
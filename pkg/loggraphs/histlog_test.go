@@ -0,0 +1,115 @@
+package loggraphs
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// TestHistValue - values of buckets are the same as plat_idx_to_val of fio
+func TestHistValue(t *testing.T) {
+	var tests = []struct {
+		index int
+		value float64
+	}{
+		{0, 0},
+		{63, 63},
+		{64, 64},
+		{127, 127},
+		{128, 129},
+		{129, 131},
+		{191, 255},
+		{192, 258},
+		{1000, 1712128},
+		{1855, 17112760320},
+	}
+	for _, test := range tests {
+		if value := histValue(test.index); value != test.value {
+			t.Errorf("histValue(%d) = %v, expected %v", test.index, value, test.value)
+		}
+		if index := histIndex(int(test.value)); index != test.index {
+			t.Errorf("histIndex(%v) = %d, expected %d", test.value, index, test.index)
+		}
+	}
+}
+
+func TestDetectHistLayout(t *testing.T) {
+	var tests = []struct {
+		bins   int
+		valid  bool
+		layout histLayout
+	}{
+		{1856, true, histLayout{stride: 1, unit: 1}},
+		{464, true, histLayout{stride: 4, unit: 1}},
+		{29, true, histLayout{stride: 64, unit: 1}},
+		{1216, true, histLayout{stride: 1, unit: 1000}},
+		{304, true, histLayout{stride: 4, unit: 1000}},
+		{1857, false, histLayout{}},
+		{100, false, histLayout{}},
+	}
+	for _, test := range tests {
+		layout, ok := detectHistLayout(test.bins)
+		if ok != test.valid || layout != test.layout {
+			t.Errorf("detectHistLayout(%d) = %+v, %v, expected %+v, %v", test.bins, layout, ok, test.layout, test.valid)
+		}
+	}
+}
+
+// histLine - line of histogram log at 2000 msec of writes of 4k with counts of IOs in bins,
+// priority is added before bins if prio is true
+func histLine(bins int, prio bool, counts map[int]uint64) string {
+	fields := []string{"2000", "1", "4096"}
+	if prio {
+		fields = append(fields, "0")
+	}
+	for bin := 0; bin < bins; bin++ {
+		fields = append(fields, fmt.Sprint(counts[bin]))
+	}
+	return strings.Join(fields, ", ")
+}
+
+func TestParseHistLine(t *testing.T) {
+	var tests = []struct {
+		name   string
+		bins   int
+		prio   bool
+		counts map[int]uint64
+		values map[int]uint64 // latency in nsec -> count of IOs
+	}{
+		{"nanoseconds, coarseness 0", 1856, false, map[int]uint64{128: 3, 1000: 2}, map[int]uint64{129: 3, 1712128: 2}},
+		{"nanoseconds, coarseness 2", 464, false, map[int]uint64{32: 4}, map[int]uint64{133: 4}},
+		{"microseconds, coarseness 0", 1216, false, map[int]uint64{128: 1}, map[int]uint64{129000: 1}},
+		{"priority column, coarseness 0", 1856, true, map[int]uint64{128: 3, 1000: 2}, map[int]uint64{129: 3, 1712128: 2}},
+		{"priority column, coarseness 2", 464, true, map[int]uint64{32: 4}, map[int]uint64{133: 4}},
+	}
+	for _, test := range tests {
+		bands := newLatencyBands(1000)
+		if err := parseHistLine(histLine(test.bins, test.prio, test.counts), bands); err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		hist, ok := bands.buckets[1][2]
+		if !ok {
+			t.Errorf("%s: no histogram of writes at 2000 msec", test.name)
+			continue
+		}
+		var total uint64
+		max := 0
+		for value, count := range test.values {
+			if hist.counts[histIndex(value)] != count {
+				t.Errorf("%s: %d IOs of %d nsec, expected %d", test.name, hist.counts[histIndex(value)], value, count)
+			}
+			total += count
+			if value > max {
+				max = value
+			}
+		}
+		if hist.total != total || hist.max != max {
+			t.Errorf("%s: %d IOs with max %d nsec, expected %d IOs with max %d nsec", test.name, hist.total, hist.max, total, max)
+		}
+	}
+
+	if err := parseHistLine(histLine(100, false, nil), newLatencyBands(1000)); err == nil {
+		t.Error("expected error for unknown layout of bins")
+	}
+}
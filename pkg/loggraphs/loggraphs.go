@@ -1,21 +1,22 @@
 package loggraphs
 
 import (
+	"bufio"
 	"bytes"
-	"encoding/csv"
 	"fmt"
-	"io"
 	"io/fs"
 	"io/ioutil"
 	"math"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 
 	"github.com/wcharczuk/go-chart/v2"
 	"github.com/wcharczuk/go-chart/v2/drawing"
 	bs "github.com/vk-en/fioplot-bs/pkg/bsdata"
+	"github.com/vk-en/fioplot-bs/pkg/units"
 )
 
 const (
@@ -32,17 +33,22 @@ const (
 // For bw log value == KiB/sec;
 // For IOPS log value == count Iops;
 // For Latency log value == latency in nsecs.
+// Logs of each IO also have block size, offset (with log_offset=1) and priority of IO.
 type LogLine struct {
 	time   int // msec
 	value  int
-	opType int // read - 0 ; write - 1 ; trim - 2
+	opType int   // read - 0 ; write - 1 ; trim - 2
+	bs     int   // bytes, 0 for averaged samples
+	offset int64 // bytes, 0 without log_offset
+	prio   int   // priority of IO (class << 13 | level), 0 in old versions of fio
 }
 
 // GroupLogFiles - group log files by test name
 type GroupLogFiles struct {
-	filesPath    []string
-	patternName  string
-	minCountLine int
+	filesPath   []string
+	patternName string
+	bands       *latencyBands  // percentiles of latency logs of each IO, nil for other logs
+	extended    *extendedStats // offsets, block sizes and priorities of IOs, nil for other logs
 }
 
 // LogGF - log files info
@@ -51,18 +57,6 @@ type LogGF []*GroupLogFiles
 // LogFile - log data
 type LogFile []*LogLine
 
-// toFixed - round float64 to fixed decimal places
-func toFixed(x float64, n int) float64 {
-	var l = math.Pow(10, float64(n))
-	var mbs = math.Round(x*l) / l
-	return mbs * 1.049 // formula from google
-}
-
-// mbps - convert bytes to MB/s
-func mbps(x int) float64 {
-	return toFixed(float64(x)/1024, 2)
-}
-
 // readDirWithResults - read directory with logs and return list of files
 func readDirWithResults(dirPath string) ([]fs.FileInfo, error) {
 	files, err := ioutil.ReadDir(dirPath)
@@ -95,35 +89,86 @@ func (t *LogFile) saveFile(filePath string, countLine int) error {
 	return nil
 }
 
-// parsingLogfile - parsing log file
-func (t *LogFile) parsingLogfile(filePath string) error {
+// logColumns - the largest count of columns of log file: time, value, direction, block size, offset, priority
+const logColumns = 6
+
+// parseLogLine - parse line of log file (Ex. "119995, 3481600, 0, 4096, 0").
+// Columns after direction are block size, offset (only if log_offset is set in job) and priority,
+// old versions of fio don't write the last columns. Valid line is parsed without allocations,
+// logs of each IO have billions of them
+func parseLogLine(text []byte, hasOffset bool) (LogLine, error) {
+	var values [logColumns]int64
+	count := 0
+	for rest := text; count < logColumns; count++ {
+		field := rest
+		comma := bytes.IndexByte(rest, ',')
+		if comma >= 0 {
+			field, rest = rest[:comma], rest[comma+1:]
+		}
+		value, err := strconv.ParseInt(string(bytes.TrimSpace(field)), 10, 64)
+		if err != nil {
+			return LogLine{}, fmt.Errorf("invalid number in line: %q", text)
+		}
+		values[count] = value
+		if comma < 0 {
+			count++
+			break
+		}
+	}
+	if count < 3 {
+		return LogLine{}, fmt.Errorf("expected time, value and direction in line: %q", text)
+	}
+	line := LogLine{time: int(values[0]), value: int(values[1]), opType: int(values[2])}
+	columns := values[3:count]
+	if len(columns) > 0 {
+		line.bs = int(columns[0])
+		columns = columns[1:]
+	}
+	if hasOffset && len(columns) > 0 {
+		line.offset = columns[0]
+		columns = columns[1:]
+	}
+	if len(columns) > 0 {
+		line.prio = int(columns[0])
+	}
+	return line, nil
+}
+
+// scanLogFile - read log file line by line without loading the whole file into memory
+func scanLogFile(filePath string, hasOffset bool, handle func(line LogLine)) error {
 	logFile, err := os.Open(filePath)
 	if err != nil {
 		return fmt.Errorf("could not open log file %s err:%w", filePath, err)
 	}
 	defer logFile.Close()
 
-	reader, err := csv.NewReader(logFile).ReadAll()
-	if err != nil {
-		return fmt.Errorf("could not read log file %s err:%w", filePath, err)
-	}
-
-	for _, line := range reader {
-		// example line [119995  3481600  0  0]
-		sTime, _ := strconv.Atoi(strings.TrimSpace(line[0]))
-		sValue, _ := strconv.Atoi(strings.TrimSpace(line[1]))
-		sOpType, _ := strconv.Atoi(strings.TrimSpace(line[2]))
-		logLine := LogLine{
-			time:   sTime,
-			value:  sValue,
-			opType: sOpType,
+	scanner := bufio.NewScanner(logFile)
+	number := 0
+	for scanner.Scan() {
+		number++
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+		line, err := parseLogLine(text, hasOffset)
+		if err != nil {
+			return fmt.Errorf("could not read log file %s line %d: %w", filePath, number, err)
 		}
-		*t = append(*t, &logLine)
+		handle(line)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("could not read log file %s err:%w", filePath, err)
 	}
-
 	return nil
 }
 
+// parsingLogfile - parsing log file, it is used for glued log files which have one line for each time bucket
+func (t *LogFile) parsingLogfile(filePath string) error {
+	return scanLogFile(filePath, false, func(line LogLine) {
+		*t = append(*t, &line)
+	})
+}
+
 func styleDefaultsSeries(c *chart.Chart, seriesIndex int) chart.Style {
 	return chart.Style{
 		DotColor:    c.GetColorPalette().GetSeriesColor(seriesIndex),
@@ -134,6 +179,40 @@ func styleDefaultsSeries(c *chart.Chart, seriesIndex int) chart.Style {
 	}
 }
 
+// directions - directions of IOs in log in ascending order (read, write, trim)
+func (t LogFile) directions() []bs.Direction {
+	found := make(map[int]bool)
+	var directions []bs.Direction
+	for _, line := range t {
+		if !found[line.opType] {
+			found[line.opType] = true
+			directions = append(directions, bs.Direction(line.opType))
+		}
+	}
+	sort.Slice(directions, func(i, j int) bool { return directions[i] < directions[j] })
+	return directions
+}
+
+// direction - lines of log with IOs of direction
+func (t LogFile) direction(direction bs.Direction) LogFile {
+	var lines LogFile
+	for _, line := range t {
+		if bs.Direction(line.opType) == direction {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// directionInfo - information for chart of one direction of job with mixed directions,
+// statistics are taken from results of the direction
+func directionInfo(info bs.LogFileInfo, direction bs.Direction) bs.LogFileInfo {
+	info.Header = fmt.Sprintf("%s  [%s]", info.Header, direction)
+	info.ImgName = fmt.Sprintf("%s-%s", info.ImgName, direction)
+	info.BasicInfoStr = basicInfo(info.FileType, info.InfoJobs.Operation(direction))
+	return info
+}
+
 // getPoints - Get values for the x-axis
 func getPoints(data LogFile, logType bs.LogFileType) ([]float64, []float64) {
 	var xPoints []float64
@@ -141,7 +220,7 @@ func getPoints(data LogFile, logType bs.LogFileType) ([]float64, []float64) {
 	for _, point := range data {
 		xPoints = append(xPoints,float64(point.time/1000)) // convert to seconds
 		if logType == bs.LOG_TYPE_BW {
-			yPoints = append(yPoints, mbps(point.value))
+			yPoints = append(yPoints, float64(point.value)*units.KiB)
 		} else {
 			yPoints = append(yPoints, float64(point.value))
 		}
@@ -315,23 +394,31 @@ func drawInfo(c *chart.Chart, info bs.LogFileInfo) chart.Renderable {
 }
 
 
-func createGraphForLog(data LogFile, logInfo bs.LogFileInfo, testName, discription, imgFormat string) error {
-
-	xVal, YVal := getPoints(data, logInfo.FileType)
-	mainSeries := chart.ContinuousSeries{
-		Name:    logInfo.YName,
-		YValues: YVal,
-		XValues: xVal,
+// logQuantity - quantity of values of log for choice of unit of Y axis
+func logQuantity(fType bs.LogFileType) units.Quantity {
+	switch fType {
+	case bs.LOG_TYPE_BW:
+		return units.Bandwidth
+	case bs.LOG_TYPE_IOPS:
+		return units.Count
 	}
+	return units.Latency
+}
 
-	smaSeries := &chart.SMASeries{
-		Name: "Average",
-		InnerSeries: mainSeries,
-		Style: chart.Style{
-			StrokeColor: drawing.ColorRed,               // will supercede defaults
-		},
+// seriesUnit - unit of Y axis chosen by values of all lines of chart
+func seriesUnit(logInfo bs.LogFileInfo, series []chart.Series) units.Unit {
+	var values []float64
+	for _, line := range series {
+		if continuous, ok := line.(chart.ContinuousSeries); ok {
+			values = append(values, continuous.YValues...)
+		}
 	}
+	return logInfo.Units.Choose(logQuantity(logInfo.FileType), values...)
+}
 
+// newLogChart - chart of log file with series over time and information about job under it
+func newLogChart(logInfo bs.LogFileInfo, series []chart.Series) chart.Chart {
+	unit := seriesUnit(logInfo, series)
 	graph := chart.Chart{
 		Width: LogChartWidth,
 		Height: LogChartHeight,
@@ -348,13 +435,10 @@ func createGraphForLog(data LogFile, logInfo bs.LogFileInfo, testName, discripti
 			},
 		},
 
-		Series: []chart.Series {
-			mainSeries,
-			smaSeries,
-		},
+		Series: series,
 
 		YAxis: chart.YAxis {
-			Name: logInfo.YName,
+			Name: unit.Label(logInfo.YName),
 			NameStyle: chart.Style{
 				FontSize:    20.0,
 			},
@@ -363,7 +447,8 @@ func createGraphForLog(data LogFile, logInfo bs.LogFileInfo, testName, discripti
 			},
 			ValueFormatter: func(v interface{}) string {
 				if vf, isFloat := v.(float64); isFloat {
-					return fmt.Sprintf("%0.0f", vf)
+					// values are in nanoseconds or bytes per second
+					return fmt.Sprintf("%g", math.Round(unit.Value(vf)*1000)/1000)
 				}
 				return ""
 			},
@@ -391,7 +476,11 @@ func createGraphForLog(data LogFile, logInfo bs.LogFileInfo, testName, discripti
 		drawlegend(&graph),
 		drawInfo(&graph, logInfo),
 	}
+	return graph
+}
 
+// saveLogChart - render chart into DirForImage/ImgName.imgFormat
+func saveLogChart(graph chart.Chart, logInfo bs.LogFileInfo, imgFormat string) error {
 	logFraphPath := filepath.Join(logInfo.DirForImage, fmt.Sprintf("%s.%s", logInfo.ImgName, imgFormat))
 	f, err := os.Create(logFraphPath)
 	if err != nil {
@@ -411,35 +500,54 @@ func createGraphForLog(data LogFile, logInfo bs.LogFileInfo, testName, discripti
 	return nil
 }
 
-// lineCounter - counts lines in  the log file
-func lineCounter(r io.Reader) (int, error) {
-	buf := make([]byte, 32*1024)
-	count := 0
-	lineSep := []byte{'\n'}
+// createGraphForLog - chart of glued log file with moving average,
+// jobs with mixed directions have a chart for each direction
+func createGraphForLog(data LogFile, logInfo bs.LogFileInfo, imgFormat string) error {
+	directions := data.directions()
+	for _, direction := range directions {
+		info := logInfo
+		if len(directions) > 1 {
+			info = directionInfo(logInfo, direction)
+		}
 
-	for {
-		c, err := r.Read(buf)
-		count += bytes.Count(buf[:c], lineSep)
+		xVal, YVal := getPoints(data.direction(direction), info.FileType)
+		mainSeries := chart.ContinuousSeries{
+			Name:    info.YName,
+			YValues: YVal,
+			XValues: xVal,
+		}
 
-		switch {
-		case err == io.EOF:
-			return count, nil
+		smaSeries := &chart.SMASeries{
+			Name: "Average",
+			InnerSeries: mainSeries,
+			Style: chart.Style{
+				StrokeColor: drawing.ColorRed,               // will supercede defaults
+			},
+		}
 
-		case err != nil:
-			return count, err
+		graph := newLogChart(info, []chart.Series{mainSeries, smaSeries})
+		if err := saveLogChart(graph, info, imgFormat); err != nil {
+			return err
 		}
 	}
+	return nil
 }
 
-// getCountLineInFile - get count lines in file
-func getCountLineInFile(path string) int {
-	file, _ := os.Open(path)
-	countLine, err := lineCounter(file)
-	if err != nil {
-		fmt.Println("counting line in file failed! file:", path, "error: ", err)
-		return 0
+// createBandsGraphs - charts of percentiles of latencies of each IO in time buckets,
+// jobs with mixed directions have a chart for each direction
+func createBandsGraphs(bands *latencyBands, logInfo bs.LogFileInfo, imgFormat string) error {
+	directions := bands.directions()
+	for _, direction := range directions {
+		info := logInfo
+		if len(directions) > 1 {
+			info = directionInfo(logInfo, direction)
+		}
+		graph := newLogChart(info, bands.series(direction))
+		if err := saveLogChart(graph, info, imgFormat); err != nil {
+			return err
+		}
 	}
-	return countLine
+	return nil
 }
 
 // SepareteLogs - separate logs
@@ -447,56 +555,61 @@ func (t *LogGF) separeteLogs(fileList []fs.FileInfo, sessionPath string) error {
 	haveName := false
 	for _, file := range fileList {
 		fTable := GroupLogFiles{}
-		countLine := getCountLineInFile(fmt.Sprintf("%s/%s", sessionPath, file.Name()))
 		fullPathToFile := fmt.Sprintf("%s/%s", sessionPath, file.Name())
 		patternFile := strings.Split(file.Name(), ".")[0]
 		haveName = false
 		for _, value := range *t {
 			if value.patternName == patternFile {
 				value.filesPath = append(value.filesPath, fullPathToFile)
-				if value.minCountLine > countLine {
-					value.minCountLine = countLine
-				}
 				haveName = true
 			}
 		}
 		if !haveName {
 			fTable.patternName = patternFile
 			fTable.filesPath = append(fTable.filesPath, fullPathToFile)
-			fTable.minCountLine = countLine
 			*t = append(*t, &fTable)
 		}
 	}
 	return nil
 }
 
-// gluingFiles - gluing log files
-func (t *LogGF) gluingFiles(resultsDir string) error {
+// gluingFiles - gluing log files of threads of each job on common time buckets of log_avg_msec
+func (t *LogGF) gluingFiles(resultsDir string, testInfo bs.TestInfo) error {
 	for _, value := range *t {
-		var logDataMainFile = make(LogFile, 0)
-		if err := logDataMainFile.parsingLogfile(value.filesPath[0]); err != nil {
-			return fmt.Errorf("error with parsing log file %w", err)
-		}
-		if len(value.filesPath) == 1 {
-			// There is nothing to glue here, just saving the file
-			err := logDataMainFile.saveFile(filepath.Join(resultsDir, fmt.Sprintf("%s.log", value.patternName)), value.minCountLine)
-			if err != nil {
-				return fmt.Errorf("error create file %w", err)
+		width, perIO := bucketMsec(testInfo, value.patternName)
+		logType := logTypeByName(value.patternName)
+		if logType == bs.LOG_TYPE_CLAT_HIST {
+			// histograms are drawn as heatmaps without glued log file
+			value.bands = newLatencyBands(width)
+			for _, path := range value.filesPath {
+				if err := readHistLog(path, value.bands); err != nil {
+					return fmt.Errorf("error with parsing histogram log file %w", err)
+				}
 			}
 			continue
-
 		}
-		for index := 1; index < len(value.filesPath); index++ {
-			var logTmpFile = make(LogFile, 0)
-			if err := logTmpFile.parsingLogfile(value.filesPath[index]); err != nil {
-				return fmt.Errorf("error with parsing log file %w", err)
+		options, _ := logOptions(testInfo, value.patternName)
+		hasOffset := options.LogOffset == "1"
+		var collectors []func(line LogLine)
+		if perIO && logType != bs.LOG_TYPE_BW && logType != bs.LOG_TYPE_IOPS {
+			value.bands = newLatencyBands(width)
+			collectors = append(collectors, value.bands.add)
+			if t.extendedLog(value.patternName) {
+				value.extended = newExtendedStats(hasOffset, testInfo.JSONResults.FioVersion)
+				collectors = append(collectors, value.extended.add)
 			}
-			for line := 0; line < value.minCountLine; line++ {
-				logDataMainFile[line].value += logTmpFile[line].value
+		}
+		var threads []map[int]*threadSeries
+		for _, path := range value.filesPath {
+			thread, err := readThread(path, width, hasOffset, collectors...)
+			if err != nil {
+				return fmt.Errorf("error with parsing log file %w", err)
 			}
+			threads = append(threads, thread)
 		}
 
-		err := logDataMainFile.saveFile(filepath.Join(resultsDir, fmt.Sprintf("%s.log", value.patternName)), value.minCountLine)
+		glued := mergeThreads(threads, logType, width)
+		err := glued.saveFile(filepath.Join(resultsDir, fmt.Sprintf("%s.log", value.patternName)), len(glued))
 		if err != nil {
 			return fmt.Errorf("error create file %w", err)
 		}
@@ -504,16 +617,22 @@ func (t *LogGF) gluingFiles(resultsDir string) error {
 	return nil
 }
 
-func GetLogFilesFromGroup(dirWithLogs, mainResultsAbsDir string, fileList []fs.FileInfo) error {
+// glueLogFiles - glue log files of threads of each job from dir with logs of test
+func glueLogFiles(dirWithLogs, mainResultsAbsDir string, fileList []fs.FileInfo, testInfo bs.TestInfo) (LogGF, error) {
 	var logGroupF = make(LogGF, 0)
 	if err := logGroupF.separeteLogs(fileList, dirWithLogs); err != nil {
-		return fmt.Errorf("error with separeteLogs %w", err)
+		return nil, fmt.Errorf("error with separeteLogs %w", err)
 	}
-	if err := logGroupF.gluingFiles(mainResultsAbsDir); err != nil {
-		return fmt.Errorf("error with gluing log files %w", err)
+	if err := logGroupF.gluingFiles(mainResultsAbsDir, testInfo); err != nil {
+		return nil, fmt.Errorf("error with gluing log files %w", err)
 	}
+	return logGroupF, nil
+}
 
-	return nil
+// GetLogFilesFromGroup - glue log files of threads of each job from dir with logs of test
+func GetLogFilesFromGroup(dirWithLogs, mainResultsAbsDir string, fileList []fs.FileInfo, testInfo bs.TestInfo) error {
+	_, err := glueLogFiles(dirWithLogs, mainResultsAbsDir, fileList, testInfo)
+	return err
 }
 
 // checkFolderWithLogs - check folder with logs and return list of files
@@ -561,100 +680,107 @@ func getTetsInfoFromJSON(allTests bs.AllTestInfo, testName string) (bs.TestInfo,
 	return bs.TestInfo{}, fmt.Errorf("test %s not found in JSON data", testName)
 }
 
-func getJobsFromTestInfo(testInfo bs.TestInfo, fileName, description string) (bs.LogFileInfo, error) {
+// jobOperation - results of the job for the direction which is described under the graph.
+// Jobs with mixed directions (randrw, trimwrite) are described by the last direction.
+func jobOperation(job *bs.Jobs, options *bs.JobOptions) *bs.OperationRW {
+	directions := bs.JobDirections(options.RW)
+	return job.Operation(directions[len(directions)-1])
+}
+
+// basicInfo - statistics of direction of job from results of fio for the type of log
+func basicInfo(fType bs.LogFileType, op *bs.OperationRW) string {
+	switch fType {
+	case bs.LOG_TYPE_BW:
+		return fmt.Sprintf("bw (Kib/s):   min=%d,   max=%d,   avg=%.2f,   stdev=%.2f,   samples=%d",
+			op.BwMin, op.BwMax, op.BwMean, op.BwDev, op.BwSamples)
+	case bs.LOG_TYPE_IOPS:
+		return fmt.Sprintf("IOPS:   min=%d,   max=%d,   avg=%.2f,   stdev=%.2f,   samples=%d",
+			op.IopsMin, op.IopsMax, op.IopsMean, op.IopsStddev, op.IopsSamples)
+	case bs.LOG_TYPE_LAT:
+		return fmt.Sprintf("lat (nsec):   min=%d,   max=%d,   avg=%.2f,   stdev=%.2f",
+			op.LatNS.Min, op.LatNS.Max, op.LatNS.Mean, op.LatNS.Stddev)
+	case bs.LOG_TYPE_CLAT, bs.LOG_TYPE_CLAT_HIST:
+		return fmt.Sprintf("clat (nsec):   min=%d,   max=%d,   avg=%.2f,   stdev=%.2f",
+			op.ClatNS.Min, op.ClatNS.Max, op.ClatNS.Mean, op.ClatNS.Stddev)
+	case bs.LOG_TYPE_SLAT:
+		return fmt.Sprintf("slat (nsec):   min=%d,   max=%d,   avg=%.2f,   stdev=%.2f",
+			op.SlatNS.Min, op.SlatNS.Max, op.SlatNS.Mean, op.SlatNS.Stddev)
+	}
+	return ""
+}
+
+func getJobsFromTestInfo(testInfo bs.TestInfo, fileName, description string, system units.System) (bs.LogFileInfo, error) {
 	logFinfo := bs.LogFileInfo{}
 	found := false
 
 	for _, job := range testInfo.JSONResults.Jobs {
+		options := job.EffectiveOptions(&testInfo.JSONResults.GlobalOptions)
+		op := jobOperation(&job, &options)
 		// Here we compare log files that have already been glued together.
 		// This means that there should not be files named like write-64k-8_bw.435.log
 		// The expected filename is something like this: write-64k-8_bw.log
 		// It consists of the name specified in the fio configuration file and
 		// the prefix with the log type
 		switch fileName {
-		case fmt.Sprintf("%s_bw.log", filepath.Base(job.TestOption.BwLog)):
+		case fmt.Sprintf("%s_bw.log", filepath.Base(options.BwLog)):
 			found = true
 			logFinfo.FileType = bs.LOG_TYPE_BW
-			logFinfo.YName = "MB/s"
+			logFinfo.YName = "Bandwidth"
 			logFinfo.Header = fmt.Sprintf("Bandwidth for %s  [test: %s]", job.TestName, testInfo.TestName)
-			logFinfo.ImgName = fmt.Sprintf("bw-%s", filepath.Base(job.TestOption.BwLog))
-			if job.TestOption.RW == "read" || job.TestOption.RW == "randread" {
-				logFinfo.BasicInfoStr = fmt.Sprintf("bw (Kib/s):   min=%d,   max=%d,   avg=%.2f,   stdev=%.2f,   samples=%d",
-							 job.Read.BwMin, job.Read.BwMax, job.Read.BwMean, job.Read.BwDev, job.Read.BwSamples)
-			} else {
-				logFinfo.BasicInfoStr = fmt.Sprintf("bw (Kib/s):   min=%d,   max=%d,   avg=%.2f,   stdev=%.2f,   samples=%d",
-							 job.Write.BwMin, job.Write.BwMax, job.Write.BwMean, job.Write.BwDev, job.Write.BwSamples)
-			}
-		case fmt.Sprintf("%s_iops.log", filepath.Base(job.TestOption.IOPSLog)):
+			logFinfo.ImgName = fmt.Sprintf("bw-%s", filepath.Base(options.BwLog))
+		case fmt.Sprintf("%s_iops.log", filepath.Base(options.IOPSLog)):
 			found = true
 			logFinfo.FileType = bs.LOG_TYPE_IOPS
 			logFinfo.YName = "IOPS"
 			logFinfo.Header = fmt.Sprintf("IOPS for %s  [test: %s]", job.TestName, testInfo.TestName)
-			logFinfo.ImgName = fmt.Sprintf("iops-%s", filepath.Base(job.TestOption.IOPSLog))
-			if job.TestOption.RW == "read" || job.TestOption.RW == "randread" {
-				logFinfo.BasicInfoStr = fmt.Sprintf("IOPS:   min=%d,   max=%d,   avg=%.2f,   stdev=%.2f,   samples=%d",
-							job.Read.IopsMin, job.Read.IopsMax, job.Read.IopsMean, job.Read.IopsStddev, job.Read.IopsSamples)
-			} else {
-				logFinfo.BasicInfoStr = fmt.Sprintf("IOPS:   min=%d,   max=%d,   avg=%.2f,   stdev=%.2f,   samples=%d",
-							job.Write.IopsMin, job.Write.IopsMax, job.Write.IopsMean, job.Write.IopsStddev, job.Write.IopsSamples)
-			}
-		case fmt.Sprintf("%s_lat.log", filepath.Base(job.TestOption.LatLog)):
+			logFinfo.ImgName = fmt.Sprintf("iops-%s", filepath.Base(options.IOPSLog))
+		case fmt.Sprintf("%s_lat.log", filepath.Base(options.LatLog)):
 			found = true
 			logFinfo.FileType = bs.LOG_TYPE_LAT
-			logFinfo.YName = "Nanoseconds"
+			logFinfo.YName = "Latency"
 			logFinfo.Header = fmt.Sprintf("Total latency for %s  [test: %s]", job.TestName, testInfo.TestName)
-			logFinfo.ImgName = fmt.Sprintf("lat-%s", filepath.Base(job.TestOption.LatLog))
-			if job.TestOption.RW == "read" || job.TestOption.RW == "randread" {
-				logFinfo.BasicInfoStr = fmt.Sprintf("lat (nsec):   min=%d,   max=%d,   avg=%.2f,   stdev=%.2f",
-							job.Read.LatNS.Min, job.Read.LatNS.Max, job.Read.LatNS.Mean, job.Read.LatNS.Stddev)
-			} else {
-				logFinfo.BasicInfoStr = fmt.Sprintf("lat (nsec):   min=%d,   max=%d,   avg=%.2f,   stdev=%.2f",
-							job.Write.LatNS.Min, job.Write.LatNS.Max, job.Write.LatNS.Mean, job.Write.LatNS.Stddev)
-			}
-		case fmt.Sprintf("%s_clat.log", filepath.Base(job.TestOption.LatLog)):
+			logFinfo.ImgName = fmt.Sprintf("lat-%s", filepath.Base(options.LatLog))
+		case fmt.Sprintf("%s_clat.log", filepath.Base(options.LatLog)):
 			found = true
 			logFinfo.FileType = bs.LOG_TYPE_CLAT
-			logFinfo.YName = "Nanoseconds"
+			logFinfo.YName = "Latency"
 			logFinfo.Header = fmt.Sprintf("Completion latency for %s  [test: %s]", job.TestName, testInfo.TestName)
-			logFinfo.ImgName = fmt.Sprintf("clat-%s", filepath.Base(job.TestOption.LatLog))
-			if job.TestOption.RW == "read" || job.TestOption.RW == "randread" {
-				logFinfo.BasicInfoStr = fmt.Sprintf("clat (nsec):   min=%d,   max=%d,   avg=%.2f,   stdev=%.2f",
-							job.Read.ClatNS.Min, job.Read.ClatNS.Max, job.Read.ClatNS.Mean, job.Read.ClatNS.Stddev)
-			} else {
-				logFinfo.BasicInfoStr = fmt.Sprintf("clat (nsec):   min=%d,   max=%d,   avg=%.2f,   stdev=%.2f",
-							job.Write.ClatNS.Min, job.Write.ClatNS.Max, job.Write.ClatNS.Mean, job.Write.ClatNS.Stddev)
-			}
-		case fmt.Sprintf("%s_slat.log", filepath.Base(job.TestOption.LatLog)):
+			logFinfo.ImgName = fmt.Sprintf("clat-%s", filepath.Base(options.LatLog))
+		case fmt.Sprintf("%s_slat.log", filepath.Base(options.LatLog)):
 			found = true
 			logFinfo.FileType = bs.LOG_TYPE_SLAT
-			logFinfo.YName = "Nanoseconds"
+			logFinfo.YName = "Latency"
 			logFinfo.Header = fmt.Sprintf("Submission latency for %s  [test: %s]", job.TestName, testInfo.TestName)
-			logFinfo.ImgName = fmt.Sprintf("slat-%s", filepath.Base(job.TestOption.LatLog))
-			if job.TestOption.RW == "read" || job.TestOption.RW == "randread" {
-				logFinfo.BasicInfoStr = fmt.Sprintf("slat (nsec):   min=%d,   max=%d,   avg=%.2f,   stdev=%.2f",
-							job.Read.SlatNS.Min, job.Read.SlatNS.Max, job.Read.SlatNS.Mean, job.Read.SlatNS.Stddev)
-			} else {
-				logFinfo.BasicInfoStr = fmt.Sprintf("slat (nsec):   min=%d,   max=%d,   avg=%.2f,   stdev=%.2f",
-							job.Write.SlatNS.Min, job.Write.SlatNS.Max, job.Write.SlatNS.Mean, job.Write.SlatNS.Stddev)
-			}
+			logFinfo.ImgName = fmt.Sprintf("slat-%s", filepath.Base(options.LatLog))
+		case fmt.Sprintf("%s_clat_hist.log", filepath.Base(options.HistLog)):
+			found = true
+			logFinfo.FileType = bs.LOG_TYPE_CLAT_HIST
+			logFinfo.YName = "Latency"
+			logFinfo.Header = fmt.Sprintf("Completion latency histogram for %s  [test: %s]", job.TestName, testInfo.TestName)
+			logFinfo.ImgName = fmt.Sprintf("clat_hist-%s", filepath.Base(options.HistLog))
 		default:
 			continue
 		}
 
 		if found {
 			logFinfo.InfoJobs = &job
+			logFinfo.BasicInfoStr = basicInfo(logFinfo.FileType, op)
 			logFinfo.TestDescription = fmt.Sprintf("job name: %s   |   bs: %s   |   iodepth: %s   |   num jobs: %s   |   rw: %s   |   group ID: %d",
-										 job.TestName, job.TestOption.BS, job.TestOption.IODepth,
-										 job.TestOption.NumJobs, job.TestOption.RW, job.GroupID)
+										 job.TestName, options.BS, options.IODepth,
+										 options.NumJobs, options.RW, job.GroupID)
+			if bs.IsMixed(options.RW) {
+				logFinfo.TestDescription += fmt.Sprintf("   |   rwmixread: %s", options.RWMixRead)
+			}
 			logFinfo.XName = "Time line in seconds"
 			logFinfo.InfoAboutFio = fmt.Sprintf("Date: %s   |   version.%s   |   IO engine: %s   |   LogAvgMsec=%s   |   size=%s   | direct=%s",
 										testInfo.JSONResults.Time, testInfo.JSONResults.FioVersion,
-										testInfo.JSONResults.GlobalOptions.Ioengine,
-										testInfo.JSONResults.GlobalOptions.LogAvgMsec,
-										testInfo.JSONResults.GlobalOptions.Size,
-										testInfo.JSONResults.GlobalOptions.Direct)
+										options.Ioengine,
+										options.LogAvgMsec,
+										options.Size,
+										options.Direct)
 			logFinfo.BSInfoString = "Created in fioplot-bs. https://github.com/vk-en/fioplot-bs"
 			logFinfo.Description = fmt.Sprintf("Description: %s", description)
+			logFinfo.Units = system
 			return logFinfo, nil
 		}
 	}
@@ -667,26 +793,33 @@ func getFinishDirForGraph(dir string, fType bs.LogFileType) string {
 	dirName := ""
 	switch fType {
 	case bs.LOG_TYPE_BW:
-		dirName = filepath.Join(dir, "bw")
+		dirName = "bw"
 	case bs.LOG_TYPE_IOPS:
-		dirName = filepath.Join(dir, "iops")
+		dirName = "iops"
 	case bs.LOG_TYPE_LAT:
-		dirName = filepath.Join(dir, "lat")
+		dirName = "lat"
 	case bs.LOG_TYPE_CLAT:
-		dirName = filepath.Join(dir, "clat")
+		dirName = "clat"
 	case bs.LOG_TYPE_SLAT:
-		dirName = filepath.Join(dir, "slat")
+		dirName = "slat"
+	case bs.LOG_TYPE_CLAT_HIST:
+		dirName = "clat_hist"
 	default:
 		return dir
 	}
 
+	return makeGraphDir(dir, dirName)
+}
+
+// makeGraphDir - create folder for graphs in dir if it does not exist
+func makeGraphDir(dir, name string) string {
+	dirName := filepath.Join(dir, name)
 	if _, err := os.Stat(dirName); os.IsNotExist(err) {
 		if err = os.Mkdir(dirName, 0755); err != nil {
 			fmt.Println("Error creating directory for graph: ", err)
 		}
 	}
 	return dirName
-
 }
 
 // CreateGraphsFromLogs - create graphs from logs
@@ -701,7 +834,16 @@ func CreateGraphsFromLogs(allResults bs.AllTestInfo) error {
 		return fmt.Errorf("could not check folder with logs %w", err)
 	}
 
-	for testName, pathToLogs := range listWithLogsFolders {
+	// tests are in order of names, so lines of overlay charts are in the same order
+	var testNames []string
+	for testName := range listWithLogsFolders {
+		testNames = append(testNames, testName)
+	}
+	sort.Strings(testNames)
+
+	overlays := newOverlayLogs()
+	for _, testName := range testNames {
+		pathToLogs := listWithLogsFolders[testName]
 		testInfo, err := getTetsInfoFromJSON(allResults, testName)
 		if err != nil {
 			fmt.Println("could not get test info %w", err)
@@ -727,9 +869,14 @@ func CreateGraphsFromLogs(allResults bs.AllTestInfo) error {
 			return fmt.Errorf("could not read dir with log files: %w", err)
 		}
 
-		if err := GetLogFilesFromGroup(pathToLogs, curentLogsAbsDir, fileWithResultsTmp); err != nil {
+		logGroups, err := glueLogFiles(pathToLogs, curentLogsAbsDir, fileWithResultsTmp, testInfo)
+		if err != nil {
 			return fmt.Errorf("could not glued log files: %w", err)
 		}
+		bands := make(map[string]*latencyBands)
+		for _, group := range logGroups {
+			bands[fmt.Sprintf("%s.log", group.patternName)] = group.bands
+		}
 
 		gluedFilesWithResults, err := readDirWithResults(curentLogsAbsDir)
 		if err != nil {
@@ -740,7 +887,7 @@ func CreateGraphsFromLogs(allResults bs.AllTestInfo) error {
 			if !fileName.IsDir() {
 				var logData = make(LogFile, 0)
 
-				logInfo, err := getJobsFromTestInfo(testInfo, fileName.Name(), allResults.Description)
+				logInfo, err := getJobsFromTestInfo(testInfo, fileName.Name(), allResults.Description, allResults.Units)
 				if err != nil {
 					return fmt.Errorf("could not get jobs from test info: %w", err)
 				}
@@ -750,10 +897,43 @@ func CreateGraphsFromLogs(allResults bs.AllTestInfo) error {
 					return fmt.Errorf("could not parse log file: %w", err)
 				}
 
-				if err := createGraphForLog(logData, logInfo, testName,
-					allResults.Description, allResults.ImgFormat); err != nil {
+				if latencies := bands[fileName.Name()]; latencies != nil {
+					err = createBandsGraphs(latencies, logInfo, allResults.ImgFormat)
+				} else {
+					err = createGraphForLog(logData, logInfo, allResults.ImgFormat)
+				}
+				if err != nil {
 					return fmt.Errorf("could not create log graphs: %w", err)
 				}
+				overlays.add(newOverlayKey(testInfo, logInfo), testName, logInfo, logData)
+			}
+		}
+
+		for _, group := range logGroups {
+			if logTypeByName(group.patternName) != bs.LOG_TYPE_CLAT_HIST {
+				continue
+			}
+			logInfo, err := getJobsFromTestInfo(testInfo, fmt.Sprintf("%s.log", group.patternName), allResults.Description, allResults.Units)
+			if err != nil {
+				return fmt.Errorf("could not get jobs from test info: %w", err)
+			}
+			logInfo.DirForImage = getFinishDirForGraph(testNameDir, logInfo.FileType)
+			if err := createHeatmaps(group.bands, logInfo, allResults.ImgFormat); err != nil {
+				return fmt.Errorf("could not create heatmaps: %w", err)
+			}
+		}
+
+		for _, group := range logGroups {
+			if group.extended == nil {
+				continue
+			}
+			logInfo, err := getJobsFromTestInfo(testInfo, fmt.Sprintf("%s.log", group.patternName), allResults.Description, allResults.Units)
+			if err != nil {
+				return fmt.Errorf("could not get jobs from test info: %w", err)
+			}
+			err = createExtendedCharts(group.extended, logInfo, testName, testNameDir, allResults.ImgFormat)
+			if err != nil {
+				return fmt.Errorf("could not create charts of extended columns of log: %w", err)
 			}
 		}
 
@@ -761,8 +941,10 @@ func CreateGraphsFromLogs(allResults bs.AllTestInfo) error {
 			return fmt.Errorf("could not delete tmp dir: %s for result: %s err:%w",
 				curentLogsAbsDir, testName, err)
 		}
-		delete(listWithLogsFolders, testName)
 	}
 
+	if err := createOverlayGraphs(overlays, mainResultsAbsDirCharts, allResults.ImgFormat); err != nil {
+		return fmt.Errorf("could not create overlay log graphs: %w", err)
+	}
 	return nil
 }
@@ -0,0 +1,218 @@
+package loggraphs
+
+import (
+	"math"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	bs "github.com/vk-en/fioplot-bs/pkg/bsdata"
+)
+
+// defaultBucketMsec - width of time bucket for logs without log_avg_msec (fio logs each IO)
+const defaultBucketMsec = 1000
+
+// threadSeries - samples of one direction of one log file (thread) resampled on time buckets
+type threadSeries struct {
+	first  int       // index of the first bucket with samples
+	sums   []float64 // sum of samples in bucket
+	counts []int     // count of samples in bucket, 0 if thread has no samples in it
+}
+
+// bucketTotal - merged value of all threads in bucket
+type bucketTotal struct {
+	sum   float64
+	count float64
+}
+
+// logTypeSuffixes - suffixes of names of log files by types of logs, "_clat_hist" is before "_clat"
+var logTypeSuffixes = []struct {
+	suffix string
+	fType  bs.LogFileType
+}{
+	{"_clat_hist", bs.LOG_TYPE_CLAT_HIST},
+	{"_bw", bs.LOG_TYPE_BW},
+	{"_iops", bs.LOG_TYPE_IOPS},
+	{"_clat", bs.LOG_TYPE_CLAT},
+	{"_slat", bs.LOG_TYPE_SLAT},
+	{"_lat", bs.LOG_TYPE_LAT},
+}
+
+// splitLogName - prefix of log files of job (write_*_log option) and type of log by name of log file
+// without index of thread (Ex. write-64k-0_clat -> write-64k-0, clat)
+func splitLogName(patternName string) (string, bs.LogFileType) {
+	for _, logType := range logTypeSuffixes {
+		if strings.HasSuffix(patternName, logType.suffix) {
+			return strings.TrimSuffix(patternName, logType.suffix), logType.fType
+		}
+	}
+	return patternName, bs.LOG_TYPE_LAT
+}
+
+// logTypeByName - type of log by name of log file without index of thread (Ex. write-64k-0_clat)
+func logTypeByName(patternName string) bs.LogFileType {
+	_, logType := splitLogName(patternName)
+	return logType
+}
+
+// logOptions - options of job which wrote log files with name of log file without index of thread.
+// The prefix of name is equal to the option of job for the type of log
+// (Ex. job_a_clat is written by job with write_lat_log=job_a, not with write_lat_log=job)
+func logOptions(testInfo bs.TestInfo, patternName string) (bs.JobOptions, bool) {
+	name, logType := splitLogName(patternName)
+	for _, job := range testInfo.JSONResults.Jobs {
+		options := job.EffectiveOptions(&testInfo.JSONResults.GlobalOptions)
+		prefix := options.LatLog
+		switch logType {
+		case bs.LOG_TYPE_BW:
+			prefix = options.BwLog
+		case bs.LOG_TYPE_IOPS:
+			prefix = options.IOPSLog
+		case bs.LOG_TYPE_CLAT_HIST:
+			prefix = options.HistLog
+		}
+		if prefix != "" && filepath.Base(prefix) == name {
+			return options, true
+		}
+	}
+	return bs.JobOptions{}, false
+}
+
+// bucketMsec - width of time buckets for log files of job: log_avg_msec (log_hist_msec for
+// histogram logs) of job which wrote them or defaultBucketMsec if logs are written for each IO
+// (true is returned then)
+func bucketMsec(testInfo bs.TestInfo, patternName string) (int, bool) {
+	options, ok := logOptions(testInfo, patternName)
+	if !ok {
+		return defaultBucketMsec, false
+	}
+	logMsec := options.LogAvgMsec
+	if logTypeByName(patternName) == bs.LOG_TYPE_CLAT_HIST {
+		logMsec = options.LogHistMsec
+	}
+	if msec, err := strconv.Atoi(logMsec); err == nil && msec > 0 {
+		return msec, false
+	}
+	return defaultBucketMsec, true
+}
+
+// addSample - put sample of log file into the time bucket of its direction. Timestamps of threads
+// drift a little from multiples of log_avg_msec, so sample is put into the nearest bucket
+func addSample(series map[int]*threadSeries, line LogLine, width int) {
+	bucket := int(math.Round(float64(line.time) / float64(width)))
+	s, ok := series[line.opType]
+	if !ok {
+		s = &threadSeries{first: bucket}
+		series[line.opType] = s
+	}
+	if bucket < s.first {
+		// timestamps are not decreasing in fio logs, but the bucket is not lost anyway
+		shift := s.first - bucket
+		s.sums = append(make([]float64, shift), s.sums...)
+		s.counts = append(make([]int, shift), s.counts...)
+		s.first = bucket
+	}
+	for len(s.sums) <= bucket-s.first {
+		s.sums = append(s.sums, 0)
+		s.counts = append(s.counts, 0)
+	}
+	s.sums[bucket-s.first] += float64(line.value)
+	s.counts[bucket-s.first]++
+}
+
+// readThread - samples of log file of one thread on time buckets by directions.
+// The file is read line by line without allocations for each line, so memory doesn't depend on count
+// of IOs in log (logs of each IO with log_avg_msec=0 have a line for each IO), but it grows with
+// duration of run: series keep a sum and a count for each time bucket of each direction.
+// Each line is also passed to collectors (Ex. percentiles of latencies of each IO), their memory
+// grows with duration and with spread of latencies: latencyBands keep a counter for each histogram
+// bucket (at most 1856) of latencies of IOs in each time bucket, statistics of block sizes and
+// priorities keep a histogram for each of them and offsets are sampled up to maxOffsetPoints
+func readThread(filePath string, width int, hasOffset bool, collectors ...func(line LogLine)) (map[int]*threadSeries, error) {
+	series := make(map[int]*threadSeries)
+	if err := scanLogFile(filePath, hasOffset, func(line LogLine) {
+		addSample(series, line, width)
+		for _, collect := range collectors {
+			collect(line)
+		}
+	}); err != nil {
+		return nil, err
+	}
+	return series, nil
+}
+
+// means - mean value of thread in each bucket between the first and the last samples of thread.
+// Buckets without samples inside of this range (two samples of thread got into one bucket
+// because of drift) are interpolated between neighbouring buckets
+func (s *threadSeries) means() []float64 {
+	values := make([]float64, len(s.sums))
+	previous := -1
+	for i := range s.sums {
+		if s.counts[i] == 0 {
+			continue
+		}
+		values[i] = s.sums[i] / float64(s.counts[i])
+		if previous >= 0 && i-previous > 1 {
+			step := (values[i] - values[previous]) / float64(i-previous)
+			for gap := previous + 1; gap < i; gap++ {
+				values[gap] = values[previous] + step*float64(gap-previous)
+			}
+		}
+		previous = i
+	}
+	return values
+}
+
+// mergeThreads - merge resampled log files of all threads of job. Bandwidth and IOPS of threads
+// are summed, latencies are averaged over all samples in bucket. Buckets before the start or after
+// the end of thread are partial: the thread did not run and it adds nothing to them,
+// so series of threads with different lengths are not truncated to the shortest
+func mergeThreads(threads []map[int]*threadSeries, logType bs.LogFileType, width int) LogFile {
+	totals := make(map[int]map[int]*bucketTotal)
+	for _, thread := range threads {
+		for opType, series := range thread {
+			if _, ok := totals[opType]; !ok {
+				totals[opType] = make(map[int]*bucketTotal)
+			}
+			for i, value := range series.means() {
+				bucket := series.first + i
+				total, ok := totals[opType][bucket]
+				if !ok {
+					total = &bucketTotal{}
+					totals[opType][bucket] = total
+				}
+				switch {
+				case logType == bs.LOG_TYPE_BW || logType == bs.LOG_TYPE_IOPS:
+					total.sum += value
+					total.count = 1
+				case series.counts[i] == 0:
+					// interpolated value has weight of one sample
+					total.sum += value
+					total.count++
+				default:
+					total.sum += series.sums[i]
+					total.count += float64(series.counts[i])
+				}
+			}
+		}
+	}
+
+	var merged LogFile
+	for opType, buckets := range totals {
+		for bucket, total := range buckets {
+			merged = append(merged, &LogLine{
+				time:   bucket * width,
+				value:  int(math.Round(total.sum / total.count)),
+				opType: opType,
+			})
+		}
+	}
+	sort.Slice(merged, func(i, j int) bool {
+		if merged[i].time != merged[j].time {
+			return merged[i].time < merged[j].time
+		}
+		return merged[i].opType < merged[j].opType
+	})
+	return merged
+}
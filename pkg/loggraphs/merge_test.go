@@ -0,0 +1,228 @@
+package loggraphs
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+
+	bs "github.com/vk-en/fioplot-bs/pkg/bsdata"
+)
+
+// readTestThread - read synthetic log of one thread on buckets of one second
+func readTestThread(path string) (map[int]*threadSeries, error) {
+	return readThread(path, 1000, false)
+}
+
+// writeLog - synthetic log of each IO (log_avg_msec=0) of run for seconds with ios IOs per second,
+// IOs are read and write by turns. Returns path and size of log
+func writeLog(tb testing.TB, seconds, ios int) (string, int64) {
+	tb.Helper()
+	path := filepath.Join(tb.TempDir(), "randrw-4k-32_clat.1.log")
+	f, err := os.Create(path)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	defer f.Close()
+	w := bufio.NewWriter(f)
+	for second := 0; second < seconds; second++ {
+		for io := 0; io < ios; io++ {
+			fmt.Fprintf(w, "%d, %d, %d, 4096, 0\n", second*1000+io*1000/ios, 20000+io%5000, io%2)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		tb.Fatal(err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return path, info.Size()
+}
+
+func TestParseLogLine(t *testing.T) {
+	var tests = []struct {
+		text      string
+		hasOffset bool
+		line      LogLine
+		valid     bool
+	}{
+		{"15, 204800, 0, 0", false, LogLine{time: 15, value: 204800}, true},
+		{"119995, 3481600, 1, 4096, 8192, 8193", true, LogLine{time: 119995, value: 3481600, opType: 1, bs: 4096, offset: 8192, prio: 8193}, true},
+		{"119995, 3481600, 1, 4096, 1", false, LogLine{time: 119995, value: 3481600, opType: 1, bs: 4096, prio: 1}, true},
+		{"15, 204800", false, LogLine{}, false},
+		{"15, x, 0, 0", false, LogLine{}, false},
+	}
+	for _, test := range tests {
+		line, err := parseLogLine([]byte(test.text), test.hasOffset)
+		if (err == nil) != test.valid || line != test.line {
+			t.Errorf("parseLogLine(%q) = %+v, %v, expected %+v, valid %v", test.text, line, err, test.line, test.valid)
+		}
+	}
+
+	text := []byte("119995, 3481600, 1, 4096, 8192, 8193")
+	if allocs := testing.AllocsPerRun(100, func() { parseLogLine(text, true) }); allocs != 0 {
+		t.Errorf("parseLogLine made %v allocations, expected 0", allocs)
+	}
+}
+
+func TestReadThreadBuckets(t *testing.T) {
+	path, _ := writeLog(t, 10, 100)
+	series, err := readTestThread(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(series) != 2 {
+		t.Fatalf("expected read and write series, got %d", len(series))
+	}
+	for direction, s := range series {
+		total := 0
+		for _, count := range s.counts {
+			total += count
+		}
+		if total != 10*50 {
+			t.Errorf("direction %d: expected %d IOs in buckets, got %d", direction, 10*50, total)
+		}
+	}
+}
+
+// TestReadThreadSize - series of log of each IO have a bucket for each second of run in each direction
+// (samples of the last second are rounded to bucket of 30 s), their size doesn't depend on count of IOs in log
+func TestReadThreadSize(t *testing.T) {
+	for _, ios := range []int{10, 1000, 10000} {
+		path, _ := writeLog(t, 30, ios)
+		series, err := readTestThread(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for direction, s := range series {
+			if len(s.sums) > 31 || len(s.counts) != len(s.sums) {
+				t.Errorf("%d IOs per second, direction %d: expected at most 31 buckets, got %d", ios, direction, len(s.sums))
+			}
+		}
+	}
+}
+
+// BenchmarkReadThread - logs of each IO of one minute with growing count of IOs per second.
+// Both B/op and live-B/op (heap which is kept by series after reading) are the same for all sizes of log,
+// only time grows with size
+func BenchmarkReadThread(b *testing.B) {
+	for _, ios := range []int{1000, 10000, 100000} {
+		b.Run(fmt.Sprintf("ios=%d", ios), func(b *testing.B) {
+			path, size := writeLog(b, 60, ios)
+			b.SetBytes(size)
+			b.ReportAllocs()
+			b.ResetTimer()
+			var live uint64
+			for i := 0; i < b.N; i++ {
+				var before, after runtime.MemStats
+				runtime.GC()
+				runtime.ReadMemStats(&before)
+				series, err := readTestThread(path)
+				if err != nil {
+					b.Fatal(err)
+				}
+				runtime.GC()
+				runtime.ReadMemStats(&after)
+				if after.HeapAlloc > before.HeapAlloc {
+					live += after.HeapAlloc - before.HeapAlloc
+				}
+				runtime.KeepAlive(series)
+			}
+			b.ReportMetric(float64(live)/float64(b.N), "live-B/op")
+		})
+	}
+}
+
+// testThread - samples of thread (time in msec, value, direction) on time buckets of width
+func testThread(width int, samples ...[3]int) map[int]*threadSeries {
+	series := make(map[int]*threadSeries)
+	for _, sample := range samples {
+		addSample(series, LogLine{time: sample[0], value: sample[1], opType: sample[2]}, width)
+	}
+	return series
+}
+
+func TestMergeThreads(t *testing.T) {
+	var tests = []struct {
+		name    string
+		logType bs.LogFileType
+		width   int
+		threads []map[int]*threadSeries
+		merged  [][3]int // time of bucket, value, direction
+	}{
+		{"bandwidth of drifting threads is summed, the longer thread is not truncated", bs.LOG_TYPE_BW, 1000,
+			[]map[int]*threadSeries{
+				testThread(1000, [3]int{1000, 10, 0}, [3]int{2010, 20, 0}, [3]int{2990, 30, 0}),
+				testThread(1000, [3]int{995, 5, 0}, [3]int{1990, 7, 0}),
+			},
+			[][3]int{{1000, 15, 0}, {2000, 27, 0}, {3000, 30, 0}}},
+		{"bucket skipped because of drift is interpolated", bs.LOG_TYPE_IOPS, 1000,
+			[]map[int]*threadSeries{
+				testThread(1000, [3]int{1000, 10, 0}, [3]int{2600, 20, 0}),
+				testThread(1000, [3]int{1000, 1, 0}, [3]int{2000, 1, 0}, [3]int{3000, 1, 0}),
+			},
+			[][3]int{{1000, 11, 0}, {2000, 16, 0}, {3000, 21, 0}}},
+		{"latency is averaged over samples of all threads", bs.LOG_TYPE_CLAT, 1000,
+			[]map[int]*threadSeries{
+				testThread(1000, [3]int{900, 100, 0}, [3]int{1100, 200, 0}, [3]int{2000, 50, 0}),
+				testThread(1000, [3]int{1000, 400, 0}),
+			},
+			[][3]int{{1000, 233, 0}, {2000, 50, 0}}},
+		{"interpolated latency has weight of one sample", bs.LOG_TYPE_LAT, 1000,
+			[]map[int]*threadSeries{
+				testThread(1000, [3]int{1000, 100, 0}, [3]int{3000, 300, 0}),
+				testThread(1000, [3]int{2000, 500, 0}),
+			},
+			[][3]int{{1000, 100, 0}, {2000, 350, 0}, {3000, 300, 0}}},
+		{"directions are merged separately on buckets of log_avg_msec", bs.LOG_TYPE_BW, 500,
+			[]map[int]*threadSeries{
+				testThread(500, [3]int{500, 10, 0}, [3]int{510, 20, 1}, [3]int{990, 30, 0}),
+				testThread(500, [3]int{520, 1, 1}, [3]int{1010, 2, 1}),
+			},
+			[][3]int{{500, 10, 0}, {500, 21, 1}, {1000, 30, 0}, {1000, 2, 1}}},
+	}
+	for _, test := range tests {
+		merged := mergeThreads(test.threads, test.logType, test.width)
+		var got [][3]int
+		for _, line := range merged {
+			got = append(got, [3]int{line.time, line.value, line.opType})
+		}
+		if !reflect.DeepEqual(got, test.merged) {
+			t.Errorf("%s: expected %v, got %v", test.name, test.merged, got)
+		}
+	}
+}
+
+// TestBucketMsec - log files are matched with job by the whole prefix of their names
+func TestBucketMsec(t *testing.T) {
+	testInfo := bs.TestInfo{JSONResults: bs.FioJSON{Jobs: []bs.Jobs{
+		{TestName: "job", TestOption: bs.JobOptions{LatLog: "job", LogAvgMsec: "100"}},
+		{TestName: "job_a", TestOption: bs.JobOptions{LatLog: "logs/job_a", HistLog: "job_a", LogAvgMsec: "500", LogHistMsec: "250"}},
+		{TestName: "io", TestOption: bs.JobOptions{LatLog: "io", BwLog: "io", LogAvgMsec: "0"}},
+	}}}
+	var tests = []struct {
+		patternName string
+		width       int
+		perIO       bool
+	}{
+		{"job_clat", 100, false},
+		{"job_a_clat", 500, false},
+		{"job_a_lat", 500, false},
+		{"job_a_clat_hist", 250, false},
+		{"job_a_bw", defaultBucketMsec, false},
+		{"io_slat", defaultBucketMsec, true},
+		{"io_bw", defaultBucketMsec, true},
+		{"unknown_clat", defaultBucketMsec, false},
+	}
+	for _, test := range tests {
+		width, perIO := bucketMsec(testInfo, test.patternName)
+		if width != test.width || perIO != test.perIO {
+			t.Errorf("%s: width %d msec, per IO %v, expected %d msec, per IO %v", test.patternName, width, perIO,
+				test.width, test.perIO)
+		}
+	}
+}
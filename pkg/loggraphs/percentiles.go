@@ -0,0 +1,189 @@
+package loggraphs
+
+import (
+	"math"
+	"math/bits"
+	"sort"
+
+	bs "github.com/vk-en/fioplot-bs/pkg/bsdata"
+	"github.com/wcharczuk/go-chart/v2"
+	"github.com/wcharczuk/go-chart/v2/drawing"
+)
+
+// histSubBits - each power of 2 of latency is split into 2^histSubBits buckets of histogram,
+// so percentiles are calculated with relative error less than 1/64. It is the same layout
+// as latency histograms of fio (FIO_IO_U_PLAT_BITS), so bins of fio histogram logs are the same buckets
+const histSubBits = 6
+
+// latencyBand - line of chart of per-IO latencies
+type latencyBand struct {
+	name       string
+	percentile float64 // 100 is max
+	color      drawing.Color
+}
+
+// latencyBandsLines - lines of chart of per-IO latencies from the lowest to the highest
+var latencyBandsLines = []latencyBand{
+	{"p50", 50, chart.ColorBlue},
+	{"p99", 99, chart.ColorGreen},
+	{"p99.9", 99.9, chart.ColorOrange},
+	{"max", 100, chart.ColorRed},
+}
+
+// latencyHistogram - log-linear histogram of latencies of IOs in time bucket
+type latencyHistogram struct {
+	counts map[int]uint64
+	total  uint64
+	sum    float64
+	max    int
+}
+
+// histIndex - index of histogram bucket for latency
+func histIndex(value int) int {
+	if value < 1<<histSubBits {
+		return value
+	}
+	exp := bits.Len(uint(value)) - 1
+	sub := (value >> (exp - histSubBits)) & (1<<histSubBits - 1)
+	return (exp-histSubBits+1)<<histSubBits + sub
+}
+
+// histValue - the middle of histogram bucket (plat_idx_to_val of fio)
+func histValue(index int) float64 {
+	if index < 2<<histSubBits {
+		return float64(index)
+	}
+	exp := index>>histSubBits + histSubBits - 1
+	sub := index & (1<<histSubBits - 1)
+	low := float64(int(1)<<exp + sub<<(exp-histSubBits))
+	return low + float64(int(1)<<(exp-histSubBits))/2
+}
+
+// add - add latencies of count IOs
+func (h *latencyHistogram) add(value int, count uint64) {
+	if value < 0 || count == 0 {
+		return
+	}
+	h.counts[histIndex(value)] += count
+	h.total += count
+	h.sum += float64(value) * float64(count)
+	if value > h.max {
+		h.max = value
+	}
+}
+
+// percentile - latency below which the percentile of IOs of bucket are, max for 100
+func (h *latencyHistogram) percentile(percentile float64) float64 {
+	if percentile >= 100 {
+		return float64(h.max)
+	}
+	indexes := make([]int, 0, len(h.counts))
+	for index := range h.counts {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+
+	rank := uint64(math.Ceil(percentile / 100 * float64(h.total)))
+	var count uint64
+	for _, index := range indexes {
+		count += h.counts[index]
+		if count >= rank {
+			// the middle of bucket can be above the max of bucket
+			return math.Min(histValue(index), float64(h.max))
+		}
+	}
+	return float64(h.max)
+}
+
+// latencyBands - distributions of latencies of each IO of all threads of job in time buckets,
+// memory depends on duration of run and spread of latencies, not on count of IOs
+type latencyBands struct {
+	width   int
+	buckets map[int]map[int]*latencyHistogram // direction -> time bucket -> histogram
+}
+
+// newLatencyBands - empty distributions on time buckets of width msec
+func newLatencyBands(width int) *latencyBands {
+	return &latencyBands{width: width, buckets: make(map[int]map[int]*latencyHistogram)}
+}
+
+// histogram - histogram of time bucket of direction for time of sample in msec
+func (b *latencyBands) histogram(time, opType int) *latencyHistogram {
+	bucket := int(math.Round(float64(time) / float64(b.width)))
+	if _, ok := b.buckets[opType]; !ok {
+		b.buckets[opType] = make(map[int]*latencyHistogram)
+	}
+	hist, ok := b.buckets[opType][bucket]
+	if !ok {
+		hist = &latencyHistogram{counts: make(map[int]uint64)}
+		b.buckets[opType][bucket] = hist
+	}
+	return hist
+}
+
+// add - add latency of IO into time bucket of its direction
+func (b *latencyBands) add(line LogLine) {
+	b.histogram(line.time, line.opType).add(line.value, 1)
+}
+
+// directions - directions of IOs in logs in ascending order (read, write, trim)
+func (b *latencyBands) directions() []bs.Direction {
+	var directions []bs.Direction
+	for opType := range b.buckets {
+		directions = append(directions, bs.Direction(opType))
+	}
+	sort.Slice(directions, func(i, j int) bool { return directions[i] < directions[j] })
+	return directions
+}
+
+// timeline - time buckets of direction in ascending order and their times in seconds
+func (b *latencyBands) timeline(direction bs.Direction) ([]int, []float64) {
+	var buckets []int
+	for bucket := range b.buckets[int(direction)] {
+		buckets = append(buckets, bucket)
+	}
+	sort.Ints(buckets)
+
+	var seconds []float64
+	for _, bucket := range buckets {
+		seconds = append(seconds, float64(bucket*b.width/1000)) // convert to seconds
+	}
+	return buckets, seconds
+}
+
+// values - value of histogram of each time bucket of direction
+func (b *latencyBands) values(direction bs.Direction, value func(h *latencyHistogram) float64) []float64 {
+	buckets, _ := b.timeline(direction)
+	var values []float64
+	for _, bucket := range buckets {
+		values = append(values, value(b.buckets[int(direction)][bucket]))
+	}
+	return values
+}
+
+// series - lines of percentiles and mean of latencies of direction over time
+func (b *latencyBands) series(direction bs.Direction) []chart.Series {
+	_, xValues := b.timeline(direction)
+	var series []chart.Series
+	for _, band := range latencyBandsLines {
+		band := band
+		series = append(series, chart.ContinuousSeries{
+			Name:    band.name,
+			XValues: xValues,
+			YValues: b.values(direction, func(h *latencyHistogram) float64 { return h.percentile(band.percentile) }),
+			Style: chart.Style{
+				StrokeColor: band.color,
+				StrokeWidth: chart.DefaultSeriesLineWidth,
+			},
+		})
+	}
+	return append(series, chart.ContinuousSeries{
+		Name:    "mean",
+		XValues: xValues,
+		YValues: b.values(direction, func(h *latencyHistogram) float64 { return h.sum / float64(h.total) }),
+		Style: chart.Style{
+			StrokeColor: drawing.ColorBlack,
+			StrokeWidth: chart.DefaultSeriesLineWidth,
+		},
+	})
+}
@@ -0,0 +1,157 @@
+package scalingchart
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	bs "github.com/vk-en/fioplot-bs/pkg/bsdata"
+	data "github.com/vk-en/fioplot-bs/pkg/getdata"
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/font"
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/plotutil"
+	"gonum.org/v1/plot/vg"
+)
+
+// errorPoints - points of line with 95% confidence intervals for plotter.YErrorBars
+type errorPoints struct {
+	plotter.XYs
+	plotter.YErrors
+}
+
+// legendPoints - points of legend with values, missing values (NaN) are skipped
+func legendPoints(table *data.ScalingTable, index int) errorPoints {
+	var points errorPoints
+	for i, x := range table.X {
+		value := table.Values[i][index]
+		if math.IsNaN(value) {
+			continue
+		}
+		ci := table.CI[i][index]
+		if math.IsNaN(ci) {
+			ci = 0
+		}
+		points.XYs = append(points.XYs, plotter.XY{X: float64(x), Y: value})
+		// metrics are not negative, so interval is cut at zero
+		points.YErrors = append(points.YErrors, struct{ Low, High float64 }{math.Min(ci, value), ci})
+	}
+	return points
+}
+
+// hasErrors - true if any point has confidence interval
+func (p errorPoints) hasErrors() bool {
+	for _, e := range p.YErrors {
+		if e.High > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// plotCreate - сreates a skeleton for plotting scaling of metric
+func plotCreate(table *data.ScalingTable, description string) *plot.Plot {
+	p := plot.New()
+	p.Title.Text = fmt.Sprintf("%s: %s", table.FileName, table.Name)
+	p.Title.TextStyle.Font.Size = font.Length(20)
+	p.Title.Padding = 20
+	p.Y.Label.Text = table.YDiscription
+	p.Y.Label.Padding = 10
+	p.Y.Min = 0
+	p.X.Label.Text = fmt.Sprintf("%s\n\n%s", table.Axis, description)
+	p.X.Label.Padding = 10
+	// iodepth and numjobs are usually powers of 2, so they are evenly spaced on log scale
+	if table.X[0] > 0 {
+		p.X.Scale = plot.LogScale{}
+	}
+	ticks := make([]plot.Tick, len(table.X))
+	for i, x := range table.X {
+		ticks[i] = plot.Tick{Value: float64(x), Label: strconv.FormatInt(x, 10)}
+	}
+	p.X.Tick.Marker = plot.ConstantTicks(ticks)
+	p.Legend.Top = true
+	p.Legend.Padding = 2
+	p.Add(plotter.NewGrid())
+	return p
+}
+
+// createScalingChart - draw one line for each test
+func createScalingChart(table *data.ScalingTable, description, filePath string) error {
+	p := plotCreate(table, description)
+	for index, legend := range table.Legends {
+		points := legendPoints(table, index)
+		if len(points.XYs) == 0 {
+			continue
+		}
+		line, marks, err := plotter.NewLinePoints(points.XYs)
+		if err != nil {
+			return fmt.Errorf("could not create line for test [%s]: %w", legend, err)
+		}
+		line.Color = plotutil.Color(index)
+		line.Width = vg.Points(1.5)
+		marks.Color = plotutil.Color(index)
+		marks.Shape = plotutil.Shape(index)
+		p.Add(line, marks)
+		p.Legend.Add(legend, line, marks)
+
+		if points.hasErrors() {
+			bars, err := plotter.NewYErrorBars(points)
+			if err != nil {
+				return fmt.Errorf("could not create error bars for test [%s]: %w", legend, err)
+			}
+			bars.Color = plotutil.Color(index)
+			p.Add(bars)
+		}
+	}
+
+	if err := p.Save(10*vg.Inch, 7*vg.Inch, filePath); err != nil {
+		return fmt.Errorf("could not save scaling chart [%s]: %w", filePath, err)
+	}
+	return nil
+}
+
+// CreateScalingCharts - generate line charts of metrics by iodepth and by numjobs
+// for patterns with the same rw and block size, one line for each test.
+// Charts are in scaling-charts/<iodepth|numjobs>/<metric>/<rw-bs>.<imgType>
+func CreateScalingCharts(csvFiles []string, description, pathForResults, imgType string, opts data.Options) error {
+	var testResults = make(data.AllResults, 0)
+	for _, file := range csvFiles {
+		if err := testResults.ParsingCSVfile(file, opts); err != nil {
+			return fmt.Errorf("parsing csv file [%s] failed: %w", file, err)
+		}
+	}
+
+	patterns, err := data.GetPatterns(testResults, opts)
+	if err != nil {
+		return fmt.Errorf("could not get patterns for report: %w", err)
+	}
+
+	count := 0
+	for _, metric := range data.Metrics(opts) {
+		var pTable = make(data.PatternsTable, 0)
+		pTable.GetPatternTable(patterns, testResults, metric)
+		for _, axis := range data.ScalingAxes {
+			tables := pTable.GetScalingTables(testResults, axis)
+			if len(tables) == 0 {
+				continue
+			}
+			dirPath := filepath.Join(pathForResults, "scaling-charts", axis, metric.FileName)
+			if err := os.MkdirAll(dirPath, 0755); err != nil {
+				return fmt.Errorf("could not create dir for scaling charts: %w", err)
+			}
+			for _, table := range tables {
+				filePath := filepath.Join(dirPath, fmt.Sprintf("%s.%s", bs.FileName(table.Name), imgType))
+				if err := createScalingChart(table, description, filePath); err != nil {
+					return err
+				}
+				count++
+			}
+		}
+	}
+	if count == 0 {
+		return fmt.Errorf("there are no patterns with the same rw and block size and different iodepth or numjobs")
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package scalingchart
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	bs "github.com/vk-en/fioplot-bs/pkg/bsdata"
+	csvt "github.com/vk-en/fioplot-bs/pkg/csvtable"
+	data "github.com/vk-en/fioplot-bs/pkg/getdata"
+)
+
+// TestLegendPoints - missing values are skipped, confidence intervals are cut at zero
+func TestLegendPoints(t *testing.T) {
+	nan := math.NaN()
+	table := &data.ScalingTable{
+		X:      []int64{1, 8, 32},
+		Values: [][]float64{{100, 110}, {nan, 210}, {300, 310}},
+		CI:     [][]float64{{nan, 5}, {nan, 500}, {20, nan}},
+	}
+	var tests = []struct {
+		legend int
+		xs     []float64
+		errors []float64 // low and high of each point
+	}{
+		{0, []float64{1, 32}, []float64{0, 0, 20, 20}},
+		{1, []float64{1, 8, 32}, []float64{5, 5, 210, 500, 0, 0}},
+	}
+	for _, test := range tests {
+		points := legendPoints(table, test.legend)
+		if len(points.XYs) != len(test.xs) || len(points.YErrors) != len(test.xs) {
+			t.Errorf("legend %d: expected %d points, got %d", test.legend, len(test.xs), len(points.XYs))
+			continue
+		}
+		for i, x := range test.xs {
+			if points.XYs[i].X != x || points.YErrors[i].Low != test.errors[2*i] || points.YErrors[i].High != test.errors[2*i+1] {
+				t.Errorf("legend %d: point %d is %v with errors %v, expected x %v with errors %v", test.legend, i,
+					points.XYs[i], points.YErrors[i], x, test.errors[2*i:2*i+2])
+			}
+		}
+	}
+	if !legendPoints(table, 0).hasErrors() || (errorPoints{}).hasErrors() {
+		t.Error("expected errors only for points with confidence intervals")
+	}
+}
+
+// TestCreateScalingChartsWithoutFamilies - error if no pattern has two values of iodepth or numjobs
+func TestCreateScalingChartsWithoutFamilies(t *testing.T) {
+	dir := t.TempDir()
+	fio := bs.FioJSON{Jobs: []bs.Jobs{
+		{TestName: "read", TestOption: bs.JobOptions{RW: "randread", BS: "4k", IODepth: "32", NumJobs: "1"}},
+		{TestName: "write", TestOption: bs.JobOptions{RW: "randwrite", BS: "4k", IODepth: "1", NumJobs: "1"}},
+	}}
+	path := filepath.Join(dir, "TestA.csv")
+	if err := csvt.ConvertJSONtoCSV(fio, path, csvt.Options{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := CreateScalingCharts([]string{path}, "", dir, "png", data.Options{}); err == nil {
+		t.Error("expected error without patterns with several iodepths or numjobs")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "scaling-charts")); !os.IsNotExist(err) {
+		t.Errorf("expected no dir of scaling charts, got %v", err)
+	}
+}
@@ -0,0 +1,137 @@
+package units
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Quantity - kind of values in reports, units are chosen for each quantity separately
+type Quantity int
+
+const (
+	// Count - values without unit (IOPS)
+	Count Quantity = iota
+	// Latency - values in nanoseconds
+	Latency
+	// Bandwidth - values in bytes per second
+	Bandwidth
+	// Size - values in bytes (offsets of IOs)
+	Size
+)
+
+// KiB - bytes in kibibyte, fio reports bandwidth in KiB/s
+const KiB = 1024
+
+// Unit - unit of quantity, Scale is count of nanoseconds or bytes per second in unit
+type Unit struct {
+	Name  string
+	Scale float64
+}
+
+// quantityUnits - units of each quantity from the smallest to the largest
+var quantityUnits = map[Quantity][]Unit{
+	Count:     {{"", 1}},
+	Latency:   {{"ns", 1}, {"µs", 1e3}, {"ms", 1e6}, {"s", 1e9}},
+	Bandwidth: {{"B/s", 1}, {"KiB/s", KiB}, {"MiB/s", KiB * KiB}, {"GiB/s", KiB * KiB * KiB}},
+	Size:      {{"B", 1}, {"KiB", KiB}, {"MiB", KiB * KiB}, {"GiB", KiB * KiB * KiB}, {"TiB", KiB * KiB * KiB * KiB}},
+}
+
+// unitAliases - names of units which are easier to type than names of units in reports
+var unitAliases = map[string]string{
+	"us": "µs",
+}
+
+// BaseUnit - unit of values of quantity without conversion: nanoseconds, bytes per second, bytes or count
+func BaseUnit(quantity Quantity) Unit {
+	return quantityUnits[quantity][0]
+}
+
+// Lookup - unit and its quantity by name or alias (Ex. "ms", "MiB/s", "us" for "µs")
+func Lookup(name string) (Unit, Quantity, bool) {
+	if alias, ok := unitAliases[name]; ok {
+		name = alias
+	}
+	for quantity, units := range quantityUnits {
+		for _, unit := range units {
+			if unit.Name != "" && unit.Name == name {
+				return unit, quantity, true
+			}
+		}
+	}
+	return Unit{}, Count, false
+}
+
+// Value - value in base units (nanoseconds, bytes per second or bytes) converted to unit
+func (u Unit) Value(base float64) float64 {
+	return base / u.Scale
+}
+
+// Base - value in unit converted to base units
+func (u Unit) Base(value float64) float64 {
+	return value * u.Scale
+}
+
+// Label - name of value with unit (Ex. "Latency min (ms)"), values without unit have only name
+func (u Unit) Label(name string) string {
+	if u.Name == "" {
+		return name
+	}
+	return fmt.Sprintf("%s (%s)", name, u.Name)
+}
+
+// Format - value in base units as short number with unit (Ex. 1500000 ns -> "1.5ms")
+func (u Unit) Format(base float64) string {
+	return strconv.FormatFloat(u.Value(base), 'g', 4, 64) + u.Name
+}
+
+// System - units of values in tables and charts. Zero value chooses units for each table
+// and chart by range of its values, units set by user are used for all of them
+type System struct {
+	fixed map[Quantity]Unit
+}
+
+// Parse - units from comma separated list of latency, bandwidth and size units (Ex. "µs,GiB/s" or "us,GiB/s"),
+// quantities without unit in list and "auto" are chosen automatically
+func Parse(list string) (System, error) {
+	system := System{fixed: make(map[Quantity]Unit)}
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" || name == "auto" {
+			continue
+		}
+		unit, quantity, ok := Lookup(name)
+		if !ok {
+			return system, fmt.Errorf("unknown unit [%s], expected: ns, µs (us), ms, s, B/s, KiB/s, MiB/s, GiB/s, B, KiB, MiB, GiB or TiB", name)
+		}
+		if _, ok := system.fixed[quantity]; ok {
+			return system, fmt.Errorf("several units for the same values in [%s]", list)
+		}
+		system.fixed[quantity] = unit
+	}
+	return system, nil
+}
+
+// Choose - unit for values of quantity in base units: unit set by user or the largest unit
+// in which the largest value is at least 1, so numbers have few digits before point.
+// NaN values are skipped, the smallest unit is for values less than 1
+func (s System) Choose(quantity Quantity, values ...float64) Unit {
+	if unit, ok := s.fixed[quantity]; ok {
+		return unit
+	}
+	units := quantityUnits[quantity]
+	largest := 0.0
+	for _, value := range values {
+		if !math.IsNaN(value) && math.Abs(value) > largest {
+			largest = math.Abs(value)
+		}
+	}
+	unit := units[0]
+	for _, candidate := range units[1:] {
+		if largest >= candidate.Scale {
+			unit = candidate
+		}
+	}
+	return unit
+}
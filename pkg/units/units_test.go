@@ -0,0 +1,112 @@
+package units
+
+import (
+	"math"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	var tests = []struct {
+		list  string
+		valid bool
+		fixed map[Quantity]string // quantity -> name of fixed unit
+	}{
+		{"auto", true, map[Quantity]string{}},
+		{"", true, map[Quantity]string{}},
+		{"ms", true, map[Quantity]string{Latency: "ms"}},
+		{"µs,MiB/s", true, map[Quantity]string{Latency: "µs", Bandwidth: "MiB/s"}},
+		{"us, GiB/s", true, map[Quantity]string{Latency: "µs", Bandwidth: "GiB/s"}},
+		{"auto,KiB/s,TiB", true, map[Quantity]string{Bandwidth: "KiB/s", Size: "TiB"}},
+		{"ms,us", false, nil},
+		{"B/s,GiB/s", false, nil},
+		{"sec", false, nil},
+		{"MB/s", false, nil},
+	}
+	for _, test := range tests {
+		system, err := Parse(test.list)
+		if (err == nil) != test.valid {
+			t.Errorf("Parse(%q): error %v, expected valid %v", test.list, err, test.valid)
+			continue
+		}
+		if !test.valid {
+			continue
+		}
+		if len(system.fixed) != len(test.fixed) {
+			t.Errorf("Parse(%q): %d fixed units, expected %d", test.list, len(system.fixed), len(test.fixed))
+		}
+		for quantity, name := range test.fixed {
+			if unit, ok := system.fixed[quantity]; !ok || unit.Name != name {
+				t.Errorf("Parse(%q): unit %q of quantity %d, expected %q", test.list, unit.Name, quantity, name)
+			}
+		}
+	}
+}
+
+func TestChoose(t *testing.T) {
+	fixed, err := Parse("ms")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tests = []struct {
+		name     string
+		system   System
+		quantity Quantity
+		values   []float64
+		unit     string
+	}{
+		{"no values", System{}, Latency, nil, "ns"},
+		{"only NaN", System{}, Latency, []float64{math.NaN(), math.NaN()}, "ns"},
+		{"below 1", System{}, Latency, []float64{0.5}, "ns"},
+		{"999 ns", System{}, Latency, []float64{999}, "ns"},
+		{"1000 ns", System{}, Latency, []float64{1000}, "µs"},
+		{"999999 ns", System{}, Latency, []float64{999999}, "µs"},
+		{"1000000 ns", System{}, Latency, []float64{1000000}, "ms"},
+		{"largest of values with NaN", System{}, Latency, []float64{5, math.NaN(), 2e9}, "s"},
+		{"negative delta", System{}, Latency, []float64{-2e6}, "ms"},
+		{"1023 B/s", System{}, Bandwidth, []float64{1023}, "B/s"},
+		{"1024 B/s", System{}, Bandwidth, []float64{1024}, "KiB/s"},
+		{"offset of 3 GiB", System{}, Size, []float64{3 << 30}, "GiB"},
+		{"count", System{}, Count, []float64{1e9}, ""},
+		{"fixed unit", fixed, Latency, []float64{999}, "ms"},
+		{"fixed unit of other quantity", fixed, Bandwidth, []float64{1 << 20}, "MiB/s"},
+	}
+	for _, test := range tests {
+		if unit := test.system.Choose(test.quantity, test.values...); unit.Name != test.unit {
+			t.Errorf("%s: unit %q, expected %q", test.name, unit.Name, test.unit)
+		}
+	}
+}
+
+func TestFormatAndLabel(t *testing.T) {
+	var tests = []struct {
+		unit   string
+		base   float64
+		format string
+		label  string
+	}{
+		{"ms", 1500000, "1.5ms", "Value (ms)"},
+		{"us", 250000, "250µs", "Value (µs)"},
+		{"ns", 999, "999ns", "Value (ns)"},
+		{"MiB/s", 3 << 20, "3MiB/s", "Value (MiB/s)"},
+		{"GiB", 1 << 29, "0.5GiB", "Value (GiB)"},
+	}
+	for _, test := range tests {
+		unit, _, ok := Lookup(test.unit)
+		if !ok {
+			t.Errorf("unknown unit %q", test.unit)
+			continue
+		}
+		if format := unit.Format(test.base); format != test.format {
+			t.Errorf("%q.Format(%v) = %q, expected %q", test.unit, test.base, format, test.format)
+		}
+		if label := unit.Label("Value"); label != test.label {
+			t.Errorf("%q.Label = %q, expected %q", test.unit, label, test.label)
+		}
+		if base := unit.Base(unit.Value(test.base)); base != test.base {
+			t.Errorf("%q: value %v is %v after conversion to unit and back", test.unit, test.base, base)
+		}
+	}
+	if label := BaseUnit(Count).Label("IOPS"); label != "IOPS" {
+		t.Errorf("label of count %q, expected %q", label, "IOPS")
+	}
+}
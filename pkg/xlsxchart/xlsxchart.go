@@ -2,6 +2,7 @@ package xlsxchart
 
 import (
 	"fmt"
+	"math"
 	"path/filepath"
 
 	data "github.com/vk-en/fioplot-bs/pkg/getdata"
@@ -40,17 +41,6 @@ const globalTpl = `{
 	}
 }`
 
-const (
-	performance uint16 = 1 << iota
-	minIOPS
-	maxIOPS
-	minBW
-	maxBW
-	minLat
-	maxLat
-	stdLat
-	p99Lat
-)
 
 //
 func genExcelfile(pathFile string) bool {
@@ -62,8 +52,9 @@ func genExcelfile(pathFile string) bool {
 	return true
 }
 
-// createExcelCharts - create charts in Excel file
-func createExcelCharts(countStroke int, filePath string) error {
+// createExcelCharts - create charts in Excel file, titles of charts are names of values
+// of sheets with units (Ex. "Latency min (ms)")
+func createExcelCharts(countStroke int, filePath string, titles map[string]string) error {
 	f, err := excelize.OpenFile(filePath)
 	if err != nil {
 		return fmt.Errorf("open %s xlsx file failed: %w", filePath, err)
@@ -102,7 +93,11 @@ func createExcelCharts(countStroke int, filePath string) error {
 			series = append(series, bar)
 		}
 
-		chartsP := fmt.Sprintf(globalTpl, series, sheet)
+		title := sheet
+		if name, ok := titles[sheet]; ok {
+			title = fmt.Sprintf("%s: %s", sheet, name)
+		}
+		chartsP := fmt.Sprintf(globalTpl, series, title)
 
 		if err := f.AddChart("Bars", fmt.Sprintf("B%d", barsIndent), chartsP); err != nil {
 			fmt.Println(err)
@@ -116,6 +111,38 @@ func createExcelCharts(countStroke int, filePath string) error {
 	return nil
 }
 
+// rowValues - values for row of table, missing values (NaN) are written as empty cells
+func rowValues(values []float64) []interface{} {
+	var row = make([]interface{}, 0, len(values))
+	for _, value := range values {
+		if math.IsNaN(value) {
+			row = append(row, nil)
+			continue
+		}
+		row = append(row, value)
+	}
+	return row
+}
+
+// statsHeader - header of table with statistics over repetitions of tests
+func statsHeader(legends []string) []string {
+	var header []string
+	for _, legend := range legends {
+		header = append(header, fmt.Sprintf("%s stddev", legend), fmt.Sprintf("%s min", legend),
+			fmt.Sprintf("%s max", legend), fmt.Sprintf("%s CI95 ±", legend))
+	}
+	return header
+}
+
+// statsValues - statistics over repetitions for row of table
+func statsValues(stats []data.Stats) []interface{} {
+	var values []float64
+	for _, s := range stats {
+		values = append(values, s.Stddev, s.Min, s.Max, s.CI95)
+	}
+	return rowValues(values)
+}
+
 //createExcelTables - generate table for all groups between different tests in Excel
 func createExcelTables(table data.PatternsTable, filePath string) error {
 
@@ -126,6 +153,9 @@ func createExcelTables(table data.PatternsTable, filePath string) error {
 
 	rowIter := 2
 	sheetName := ""
+	// statistics are written after empty column, so they are not in the charts
+	withStats := table.HasRepetitions()
+	statsColumn := 0
 	for _, pattern := range table {
 		if sheetName != pattern.FileName {
 			sheetName = pattern.FileName
@@ -140,15 +170,31 @@ func createExcelTables(table data.PatternsTable, filePath string) error {
 			if err := f.SetSheetRow(sheetName, "B1", &pattern.Legends); err != nil {
 				return fmt.Errorf("could not set row: %w", err)
 			}
+			if withStats {
+				statsColumn = len(pattern.Legends) + 3
+				cell, _ := excelize.CoordinatesToCellName(statsColumn, 1)
+				header := statsHeader(pattern.Legends)
+				if err := f.SetSheetRow(sheetName, cell, &header); err != nil {
+					return fmt.Errorf("could not set row: %w", err)
+				}
+			}
 			rowIter = 2
 		}
 		var patternName = []string{pattern.PatternName}
 		if err := f.SetSheetRow(sheetName, fmt.Sprintf("A%d", rowIter), &patternName); err != nil {
 			return fmt.Errorf("could not set row: %w", err)
 		}
-		if err := f.SetSheetRow(sheetName, fmt.Sprintf("B%d", rowIter), &pattern.Values); err != nil {
+		var values = rowValues(pattern.Values)
+		if err := f.SetSheetRow(sheetName, fmt.Sprintf("B%d", rowIter), &values); err != nil {
 			return fmt.Errorf("could not set row: %w", err)
 		}
+		if withStats {
+			cell, _ := excelize.CoordinatesToCellName(statsColumn, rowIter)
+			var stats = statsValues(pattern.Stats)
+			if err := f.SetSheetRow(sheetName, cell, &stats); err != nil {
+				return fmt.Errorf("could not set row: %w", err)
+			}
+		}
 		rowIter++
 	}
 
@@ -160,8 +206,207 @@ func createExcelTables(table data.PatternsTable, filePath string) error {
 	return nil
 }
 
+// deltaColorScale - conditional format for deltas: regressions are red, improvements are green
+const deltaColorScale = `[{"type":"3_color_scale","criteria":"=","min_type":"num","min_value":"%g",` +
+	`"mid_type":"num","mid_value":"0","max_type":"num","max_value":"%g",` +
+	`"min_color":"%s","mid_color":"#FFFFFF","max_color":"%s"}]`
+
+const (
+	regressionColor  = "#F8696B"
+	improvementColor = "#63BE7B"
+)
+
+// deltaSheetName - name of sheet with deltas to baseline for metric
+func deltaSheetName(metric data.Metric) string {
+	return fmt.Sprintf("%s_delta", metric.FileName)
+}
+
+// createDeltaSheet - generate table with absolute values and color-scaled percent deltas to baseline
+func createDeltaSheet(table data.PatternsTable, metric data.Metric, baseline, filePath string) error {
+	if len(table) == 0 {
+		return nil
+	}
+	f, err := excelize.OpenFile(filePath)
+	if err != nil {
+		return fmt.Errorf("open %s xlsx file failed: %w", filePath, err)
+	}
+
+	sheetName := deltaSheetName(metric)
+	f.NewSheet(sheetName)
+	if err := f.SetColWidth(sheetName, "A", "A", 25); err != nil {
+		return fmt.Errorf("could not set column width: %w", err)
+	}
+
+	var candidates []string
+	var header = []string{"Pattern"}
+	header = append(header, table[0].Legends...)
+	for _, legend := range table[0].Legends {
+		if legend != baseline {
+			candidates = append(candidates, legend)
+			header = append(header, fmt.Sprintf("%s Δ%% to %s", legend, baseline))
+		}
+	}
+	if err := f.SetSheetRow(sheetName, "A1", &header); err != nil {
+		return fmt.Errorf("could not set row: %w", err)
+	}
+
+	maxDelta := 1.0
+	for index, pattern := range table {
+		var row = []interface{}{pattern.PatternName}
+		row = append(row, rowValues(pattern.Values)...)
+		var deltas []float64
+		for _, legend := range candidates {
+			delta := math.NaN()
+			if i := pattern.LegendIndex(legend); i >= 0 && i < len(pattern.Deltas) {
+				delta = data.Round(pattern.Deltas[i])
+			}
+			if !math.IsNaN(delta) && math.Abs(delta) > maxDelta {
+				maxDelta = math.Abs(delta)
+			}
+			deltas = append(deltas, delta)
+		}
+		row = append(row, rowValues(deltas)...)
+		if err := f.SetSheetRow(sheetName, fmt.Sprintf("A%d", index+2), &row); err != nil {
+			return fmt.Errorf("could not set row: %w", err)
+		}
+	}
+
+	if len(candidates) != 0 {
+		first, _ := excelize.CoordinatesToCellName(len(table[0].Legends)+2, 2)
+		last, _ := excelize.CoordinatesToCellName(len(table[0].Legends)+len(candidates)+1, len(table)+1)
+		minColor, maxColor := regressionColor, improvementColor
+		if metric.LowerIsBetter {
+			minColor, maxColor = improvementColor, regressionColor
+		}
+		if err := f.SetConditionalFormat(sheetName, fmt.Sprintf("%s:%s", first, last),
+			fmt.Sprintf(deltaColorScale, -maxDelta, maxDelta, minColor, maxColor)); err != nil {
+			return fmt.Errorf("could not set color scale: %w", err)
+		}
+	}
+
+	if err := f.SaveAs(filePath); err != nil {
+		return fmt.Errorf("could save xlsx file failed %w", err)
+	}
+	return nil
+}
+
+// createCoverageSheet - generate matrix of patterns and tests which have results of them
+func createCoverageSheet(coverage data.Coverage, filePath string) error {
+	f, err := excelize.OpenFile(filePath)
+	if err != nil {
+		return fmt.Errorf("open %s xlsx file failed: %w", filePath, err)
+	}
+
+	sheetName := "Coverage"
+	f.NewSheet(sheetName)
+	if err := f.SetColWidth(sheetName, "A", "A", 25); err != nil {
+		return fmt.Errorf("could not set column width: %w", err)
+	}
+	for index, record := range coverage.Records() {
+		if err := f.SetSheetRow(sheetName, fmt.Sprintf("A%d", index+1), &record); err != nil {
+			return fmt.Errorf("could not set row: %w", err)
+		}
+	}
+
+	if err := f.SaveAs(filePath); err != nil {
+		return fmt.Errorf("could save xlsx file failed %w", err)
+	}
+	return nil
+}
+
+const lineTpl = `{
+	"type": "line",
+	"series":
+	%s
+	,
+	"y_axis":
+	{
+		"major_grid_lines": true
+	},
+	"x_axis":
+	{
+		"major_grid_lines": true
+	},
+	"legend":
+	{
+		"position": "right",
+		"show_legend_key": true
+	},
+	"title":
+	{
+		"name": "%s"
+	}
+}`
+
+// scalingBlockRows - rows for table and line chart of one scaling table in sheet
+const scalingBlockRows = 17
+
+// scalingSheetName - name of sheet with scaling tables by axis
+func scalingSheetName(axis string) string {
+	return fmt.Sprintf("Scaling_%s", axis)
+}
+
+// createScalingSheet - generate tables of metrics by iodepth or numjobs with a line chart for each table
+func createScalingSheet(tables []*data.ScalingTable, axis, filePath string) error {
+	if len(tables) == 0 {
+		return nil
+	}
+	f, err := excelize.OpenFile(filePath)
+	if err != nil {
+		return fmt.Errorf("open %s xlsx file failed: %w", filePath, err)
+	}
+
+	sheetName := scalingSheetName(axis)
+	f.NewSheet(sheetName)
+	row := 1
+	for _, table := range tables {
+		title := []string{fmt.Sprintf("%s: %s, %s", table.FileName, table.Name, table.YDiscription)}
+		if err := f.SetSheetRow(sheetName, fmt.Sprintf("A%d", row), &title); err != nil {
+			return fmt.Errorf("could not set row: %w", err)
+		}
+		header := append([]string{axis}, table.Legends...)
+		if err := f.SetSheetRow(sheetName, fmt.Sprintf("A%d", row+1), &header); err != nil {
+			return fmt.Errorf("could not set row: %w", err)
+		}
+		first, last := row+2, row+1+len(table.X)
+		for i, x := range table.X {
+			values := append([]interface{}{x}, rowValues(table.Values[i])...)
+			if err := f.SetSheetRow(sheetName, fmt.Sprintf("A%d", first+i), &values); err != nil {
+				return fmt.Errorf("could not set row: %w", err)
+			}
+		}
+
+		var series []string
+		point := ","
+		for index, legend := range table.Legends {
+			column, _ := excelize.ColumnNumberToName(index + 2)
+			if index == len(table.Legends)-1 {
+				point = ""
+			}
+			series = append(series, fmt.Sprintf(barTpl, legend,
+				sheetName, fmt.Sprintf("$A$%d:$A$%d", first, last),
+				sheetName, fmt.Sprintf("$%s$%d:$%s$%d", column, first, column, last), point))
+		}
+		chartCell, _ := excelize.CoordinatesToCellName(len(table.Legends)+3, row)
+		if err := f.AddChart(sheetName, chartCell, fmt.Sprintf(lineTpl, series, title[0])); err != nil {
+			return fmt.Errorf("could not add line chart: %w", err)
+		}
+
+		blockRows := len(table.X) + 3
+		if blockRows < scalingBlockRows {
+			blockRows = scalingBlockRows
+		}
+		row += blockRows
+	}
+
+	if err := f.SaveAs(filePath); err != nil {
+		return fmt.Errorf("could save xlsx file failed %w", err)
+	}
+	return nil
+}
+
 // CreateXlsxReport - create xlsx report with table and charts
-func CreateXlsxReport(csvFiles []string, pathForResults string) error {
+func CreateXlsxReport(csvFiles []string, pathForResults string, opts data.Options) error {
 	var testResults = make(data.AllResults, 0)
 	countStroke := 0
 
@@ -172,28 +417,63 @@ func CreateXlsxReport(csvFiles []string, pathForResults string) error {
 	}
 
 	for _, file := range csvFiles {
-		if err := testResults.ParsingCSVfile(file); err != nil {
+		if err := testResults.ParsingCSVfile(file, opts); err != nil {
 			return fmt.Errorf("could not parse csv file: %w", err)
 		}
 	}
 
-	identicalPatterns, err := data.GetIdenticalPatterns(testResults)
+	identicalPatterns, err := data.GetPatterns(testResults, opts)
 	if err != nil {
-		return fmt.Errorf("could not get identical patterns: %w", err)
+		return fmt.Errorf("could not get patterns for report: %w", err)
 	}
 
-	for _, valRes := range []uint16{performance, minIOPS, maxIOPS, minBW, maxBW, minLat, maxLat, stdLat, p99Lat} {
+	var deltaTables []data.PatternsTable
+	var titles = make(map[string]string)
+	var scalingTables = make(map[string][]*data.ScalingTable)
+	metrics := data.Metrics(opts)
+	for _, metric := range metrics {
 		var pTable = make(data.PatternsTable, 0)
-		pTable.GetPatternTable(identicalPatterns, testResults, valRes)
+		pTable.GetPatternTable(identicalPatterns, testResults, metric)
 		if err := createExcelTables(pTable, mainResultsFile); err != nil {
 			return fmt.Errorf("could not create table in Xlsx file: %w", err)
 		}
 		countStroke = len(pTable)
+		if len(pTable) != 0 {
+			titles[metric.FileName] = pTable[0].YDiscription
+		}
+		if opts.Scaling {
+			for _, axis := range data.ScalingAxes {
+				scalingTables[axis] = append(scalingTables[axis], pTable.GetScalingTables(testResults, axis)...)
+			}
+		}
+		if opts.Baseline != "" {
+			if err := pTable.CompareWithBaseline(opts.Baseline); err != nil {
+				return err
+			}
+			deltaTables = append(deltaTables, pTable)
+		}
 	}
 
-	if err := createExcelCharts(countStroke+1, mainResultsFile); err != nil {
+	if err := createExcelCharts(countStroke+1, mainResultsFile, titles); err != nil {
 		return fmt.Errorf("could not create excel charts: %w", err)
 	}
 
+	// sheets with deltas are added after charts, charts are only for absolute values
+	for index, pTable := range deltaTables {
+		if err := createDeltaSheet(pTable, metrics[index], opts.Baseline, mainResultsFile); err != nil {
+			return fmt.Errorf("could not create sheet with deltas in Xlsx file: %w", err)
+		}
+	}
+
+	for _, axis := range data.ScalingAxes {
+		if err := createScalingSheet(scalingTables[axis], axis, mainResultsFile); err != nil {
+			return fmt.Errorf("could not create sheet with scaling in Xlsx file: %w", err)
+		}
+	}
+
+	if err := createCoverageSheet(data.GetCoverage(testResults, opts), mainResultsFile); err != nil {
+		return fmt.Errorf("could not create sheet with coverage in Xlsx file: %w", err)
+	}
+
 	return nil
 }